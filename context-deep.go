@@ -15,8 +15,10 @@
 package context
 
 import (
+	"reflect"
 	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/maruel/natural"
 )
@@ -82,3 +84,150 @@ func (c Context) AsDeepKeyed() (out Context) {
 	}
 	return
 }
+
+// DeepValue returns the value found at the given .deep.key path, using the
+// same syntax as DeepKeys and AsDeepKeyed (the leading period is optional).
+// Each named step is resolved with GetKV semantics on any map with string keys
+// and indexed steps support any slice or array other than []byte, the same
+// values the CQL list operators accept.
+// Named steps which are not plain identifiers can be double-quoted, such as
+// .Meta."publish-date"
+//
+// The found return value is true when the last step of the path is present,
+// even if the value itself is nil
+//
+// Examples:
+//
+//	Context{"one": Contexts{{"two": "deep"}}}.DeepValue(".one[0].two")
+//	// value == "deep", found == true
+//
+//	Context{"one": map[string]interface{}{"two": nil}}.DeepValue("one.two")
+//	// value == nil, found == true
+func (c Context) DeepValue(key string) (value interface{}, found bool) {
+	steps, ok := parseDeepKey(key)
+	if !ok {
+		return
+	}
+
+	value = c
+	for _, step := range steps {
+		if step.name != "" {
+			var ctx Context
+			switch t := value.(type) {
+			case Context:
+				ctx = t
+			case map[string]interface{}:
+				ctx = t
+			default:
+				if ctx, ok = deepMap(value); !ok {
+					return nil, false
+				}
+			}
+			var k string
+			k, value = ctx.GetKV(step.name)
+			if _, found = ctx[k]; !found {
+				return nil, false
+			}
+			continue
+		}
+
+		switch t := value.(type) {
+		case Contexts:
+			if found = step.index < len(t); found {
+				value = t[step.index]
+			}
+		case []Context:
+			if found = step.index < len(t); found {
+				value = t[step.index]
+			}
+		case []map[string]interface{}:
+			if found = step.index < len(t); found {
+				value = t[step.index]
+			}
+		case []interface{}:
+			if found = step.index < len(t); found {
+				value = t[step.index]
+			}
+		case []byte:
+			found = false
+		default:
+			rv := reflect.ValueOf(value)
+			switch rv.Kind() {
+			case reflect.Slice, reflect.Array:
+				if found = step.index < rv.Len(); found {
+					value = rv.Index(step.index).Interface()
+				}
+			default:
+				found = false
+			}
+		}
+		if !found {
+			return nil, false
+		}
+	}
+	return
+}
+
+// deepMap returns the given value as a Context if it is a map with string keys,
+// such as a map[string]string
+func deepMap(value interface{}) (ctx Context, ok bool) {
+	rv := reflect.ValueOf(value)
+	if !rv.IsValid() || rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+		return
+	}
+	ctx = make(Context, rv.Len())
+	for iter := rv.MapRange(); iter.Next(); {
+		ctx[iter.Key().String()] = iter.Value().Interface()
+	}
+	ok = true
+	return
+}
+
+type deepKeyStep struct {
+	name  string
+	index int
+}
+
+// parseDeepKey splits a .deep.key path into named and indexed steps
+func parseDeepKey(key string) (steps []deepKeyStep, ok bool) {
+	key = strings.TrimPrefix(key, ".")
+	for key != "" {
+		if key[0] == '[' {
+			end := strings.IndexByte(key, ']')
+			if end < 0 {
+				return nil, false
+			}
+			idx, err := strconv.Atoi(key[1:end])
+			if err != nil || idx < 0 {
+				return nil, false
+			}
+			steps = append(steps, deepKeyStep{index: idx})
+			key = key[end+1:]
+		} else if key[0] == '"' {
+			// quoted segments allow any characters, such as "publish-date"
+			quoted, err := strconv.QuotedPrefix(key)
+			if err != nil || len(quoted) == 2 {
+				return nil, false
+			}
+			name, _ := strconv.Unquote(quoted)
+			steps = append(steps, deepKeyStep{name: name})
+			key = key[len(quoted):]
+		} else {
+			end := strings.IndexAny(key, ".[\"")
+			if end < 0 {
+				end = len(key)
+			} else if end == 0 {
+				return nil, false
+			}
+			steps = append(steps, deepKeyStep{name: key[:end]})
+			key = key[end:]
+		}
+		if strings.HasPrefix(key, ".") {
+			if key = key[1:]; key == "" {
+				return nil, false
+			}
+		}
+	}
+	ok = len(steps) > 0 && steps[0].name != ""
+	return
+}
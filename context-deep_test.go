@@ -52,4 +52,71 @@ func TestDeep(t *testing.T) {
 			".one[0].two": "many",
 		})
 	})
+	Convey("DeepValue", t, func() {
+		ctx := Context{
+			"Author": Context{"Name": "Ann"},
+			"Items": Contexts{
+				{"Title": "first"},
+				{"Title": "second", "Empty": nil},
+			},
+			"List": []interface{}{
+				map[string]interface{}{"name": "inner"},
+				"plain",
+			},
+			"Maps":   []map[string]interface{}{{"one": 1}},
+			"Tags":   []string{"cql", "go"},
+			"Grid":   [2][]int{{1, 2}, {3}},
+			"Bytes":  []byte("raw"),
+			"Labels": map[string]string{"kind": "page", "the-key": "quoted"},
+			"Nested": map[string][]string{"tags": {"a", "b"}},
+			"more": map[string]interface{}{
+				"this":    "that",
+				"the-key": "quoted",
+			},
+		}
+
+		for _, test := range []struct {
+			key   string
+			value interface{}
+			found bool
+		}{
+			{"Author.Name", "Ann", true},
+			{".Author.Name", "Ann", true},
+			{".author.name", "Ann", true},
+			{"Items[1].Title", "second", true},
+			{"Items[1].Empty", nil, true},
+			{"Items[2].Title", nil, false},
+			{"List[0].name", "inner", true},
+			{"List[1]", "plain", true},
+			{"List[1].name", nil, false},
+			{"Maps[0].one", 1, true},
+			{"Tags[0]", "cql", true},
+			{"Tags[1]", "go", true},
+			{"Tags[2]", nil, false},
+			{"Tags[0].name", nil, false},
+			{"Grid[0][1]", 2, true},
+			{"Grid[1][1]", nil, false},
+			{"Bytes[0]", nil, false},
+			{"Labels.kind", "page", true},
+			{"Labels.Kind", "page", true},
+			{`Labels."the-key"`, "quoted", true},
+			{"Labels.missing", nil, false},
+			{"Nested.tags[1]", "b", true},
+			{".more.this", "that", true},
+			{"Missing", nil, false},
+			{"Author..Name", nil, false},
+			{"Author.", nil, false},
+			{"[0]", nil, false},
+			{"Items[x]", nil, false},
+			{"", nil, false},
+			{`more."the-key"`, "quoted", true},
+			{`."more".this`, "that", true},
+			{`more.""`, nil, false},
+			{`more."open`, nil, false},
+		} {
+			value, found := ctx.DeepValue(test.key)
+			So(found, ShouldEqual, test.found)
+			So(value, ShouldEqual, test.value)
+		}
+	})
 }
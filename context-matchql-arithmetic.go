@@ -0,0 +1,192 @@
+// Copyright (c) 2024  The Go-CoreLibs Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package context
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/go-corelibs/context/cql"
+	"github.com/go-corelibs/maths"
+)
+
+// queryArithmetic resolves both operands and applies the operator, errors are
+// prefixed with the arithmetic expression
+func (c Context) queryArithmetic(q *Query, a *cql.Arithmetic) (value interface{}, err error) {
+	var left, right interface{}
+	if left, _, err = c.queryResolve(q, a.Left); err != nil {
+		return
+	} else if right, _, err = c.queryResolve(q, a.Right); err != nil {
+		return
+	}
+	if value, err = queryApply(a.Operator, left, right); err != nil {
+		err = fmt.Errorf("%v: %w", queryLabel(&cql.Value{Arithmetic: a}), err)
+	}
+	return
+}
+
+var errDivisionByZero = errors.New("division by zero")
+
+// queryCoerceNumber is like queryNumber and also converts numeric strings, and
+// byte slices, the same as maths.ToNumber. Converted values which are whole
+// numbers are ints and all others are floats
+func queryCoerceNumber(input interface{}) (i int64, f float64, isInt, ok bool) {
+	if i, f, isInt, ok = queryNumber(input); ok {
+		return
+	}
+	if f, ok = maths.ToNumber[float64](input); ok {
+		if f == math.Trunc(f) && math.Abs(f) < math.MaxInt64 {
+			i, isInt = int64(f), true
+		}
+	}
+	return
+}
+
+// queryApply returns the result of the arithmetic operator on the values
+// given, supporting numbers, the concatenation of strings, durations added to
+// or subtracted from times, durations added to or subtracted from each other
+// and the duration between two times
+func queryApply(operator string, left, right interface{}) (value interface{}, err error) {
+	switch lt := left.(type) {
+
+	case string:
+		if rt, ok := right.(string); ok && operator == "+" {
+			value = lt + rt
+			return
+		}
+
+	case time.Time:
+		switch rt := right.(type) {
+		case time.Duration:
+			switch operator {
+			case "+":
+				value = lt.Add(rt)
+				return
+			case "-":
+				value = lt.Add(-rt)
+				return
+			}
+		case time.Time:
+			if operator == "-" {
+				value = lt.Sub(rt)
+				return
+			}
+		}
+
+	case time.Duration:
+		switch rt := right.(type) {
+		case time.Duration:
+			switch operator {
+			case "+":
+				value = lt + rt
+				return
+			case "-":
+				value = lt - rt
+				return
+			}
+		case time.Time:
+			if operator == "+" {
+				value = rt.Add(lt)
+				return
+			}
+		}
+
+	}
+
+	if !queryTemporal(left) && !queryTemporal(right) {
+		var done bool
+		if value, done, err = queryApplyNumbers(operator, left, right); done {
+			return
+		}
+	}
+
+	err = fmt.Errorf("cannot apply %v to %T and %T", operator, left, right)
+	return
+}
+
+// queryTemporal reports whether the given value is a time.Time or
+// time.Duration, which are never used as numbers
+func queryTemporal(value interface{}) (temporal bool) {
+	switch value.(type) {
+	case time.Time, time.Duration:
+		temporal = true
+	}
+	return
+}
+
+// queryApplyNumbers returns the result of the arithmetic operator on two
+// numbers, done is false when either value is not a number. Integers of any
+// size are promoted to int64 and the result is a float64 when either value is
+// a float. Integer division truncates and dividing by zero is an error for
+// both integers and floats
+//
+// Except for +, which concatenates strings, numeric strings are converted the
+// same as maths.ToNumber (and so the Int64 and Float64 accessors), see
+// queryCoerceNumber
+func queryApplyNumbers(operator string, left, right interface{}) (value interface{}, done bool, err error) {
+	number := queryNumber
+	if operator != "+" {
+		number = queryCoerceNumber
+	}
+	li, lf, lInt, lok := number(left)
+	ri, rf, rInt, rok := number(right)
+	if done = lok && rok; !done {
+		return
+	}
+
+	if lInt && rInt {
+		switch operator {
+		case "+":
+			value = li + ri
+		case "-":
+			value = li - ri
+		case "*":
+			value = li * ri
+		case "/", "%":
+			if ri == 0 {
+				err = errDivisionByZero
+			} else if operator == "/" {
+				value = li / ri
+			} else {
+				value = li % ri
+			}
+		default:
+			done = false
+		}
+		return
+	}
+
+	switch operator {
+	case "+":
+		value = lf + rf
+	case "-":
+		value = lf - rf
+	case "*":
+		value = lf * rf
+	case "/", "%":
+		if rf == 0 {
+			err = errDivisionByZero
+		} else if operator == "/" {
+			value = lf / rf
+		} else {
+			value = math.Mod(lf, rf)
+		}
+	default:
+		done = false
+	}
+	return
+}
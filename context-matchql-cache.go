@@ -0,0 +1,120 @@
+// Copyright (c) 2024  The Go-CoreLibs Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package context
+
+import (
+	"container/list"
+	"regexp"
+	"sync"
+
+	"github.com/go-corelibs/regexps"
+)
+
+// DefaultRegexpCacheSize is the initial number of compiled regular expressions
+// retained for use by context queries
+const DefaultRegexpCacheSize = 256
+
+var _rxc = newRegexpCache(DefaultRegexpCacheSize)
+
+// SetRegexpCacheSize changes the maximum number of compiled regular
+// expressions retained for use by context queries, evicting the least recently
+// used entries when the cache is over the new size. A size less than one
+// disables caching
+func SetRegexpCacheSize(size int) {
+	_rxc.resize(size)
+}
+
+// regexpCache is a concurrency-safe regexps.Cache which retains at most size
+// entries, evicting the least recently used entry first
+type regexpCache struct {
+	size  int
+	order *list.List
+	data  map[string]*list.Element
+
+	m *sync.Mutex
+}
+
+type regexpCacheEntry struct {
+	pattern string
+	rx      *regexp.Regexp
+	err     error
+}
+
+var _ regexps.Cache = (*regexpCache)(nil)
+
+func newRegexpCache(size int) (c *regexpCache) {
+	c = &regexpCache{
+		size:  size,
+		order: list.New(),
+		data:  make(map[string]*list.Element),
+		m:     &sync.Mutex{},
+	}
+	return
+}
+
+// Compile returns the cached results of compiling the pattern, including
+// errors, compiling and caching the pattern if not already present
+func (c *regexpCache) Compile(pattern string) (rx *regexp.Regexp, err error) {
+	c.m.Lock()
+	if elem, ok := c.data[pattern]; ok {
+		c.order.MoveToFront(elem)
+		entry := elem.Value.(*regexpCacheEntry)
+		c.m.Unlock()
+		return entry.rx, entry.err
+	}
+	c.m.Unlock()
+
+	rx, err = regexp.Compile(pattern)
+
+	c.m.Lock()
+	defer c.m.Unlock()
+	if _, present := c.data[pattern]; !present && c.size > 0 {
+		c.data[pattern] = c.order.PushFront(&regexpCacheEntry{pattern: pattern, rx: rx, err: err})
+		c.evict()
+	}
+	return
+}
+
+// Reset clears the existing cache
+func (c *regexpCache) Reset() {
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.order.Init()
+	c.data = make(map[string]*list.Element)
+}
+
+// Len returns the number of entries cached
+func (c *regexpCache) Len() int {
+	c.m.Lock()
+	defer c.m.Unlock()
+	return c.order.Len()
+}
+
+func (c *regexpCache) resize(size int) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	c.size = size
+	c.evict()
+}
+
+// evict removes the least recently used entries over the size, the caller
+// must hold the lock
+func (c *regexpCache) evict() {
+	for c.order.Len() > 0 && c.order.Len() > c.size {
+		last := c.order.Back()
+		c.order.Remove(last)
+		delete(c.data, last.Value.(*regexpCacheEntry).pattern)
+	}
+}
@@ -0,0 +1,241 @@
+// Copyright (c) 2024  The Go-CoreLibs Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package context
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-corelibs/context/cql"
+)
+
+// Explanation is a trace of evaluating a context query statement against a
+// specific Context, mirroring the structure of the cql.Expression evaluated
+type Explanation struct {
+	// Expression is the expression evaluated
+	Expression *cql.Expression
+	// Left and Right are the explanations of the condition operands, Left is
+	// nil for NOT conditions
+	Left, Right *Explanation
+	// LeftValue and RightValue are the resolved operation operands, the
+	// LeftValue of an ANY or ALL expression is the value of its list
+	LeftValue, RightValue interface{}
+	// Items are the explanations of the quantified expression for each item
+	// of an ANY or ALL expression evaluated, in order
+	Items []*Explanation
+	// Matched is the result of this expression
+	Matched bool
+	// Skipped is true when this expression was not evaluated because of
+	// short-circuiting
+	Skipped bool
+	// Err is the evaluation error of this expression, if any
+	Err error
+}
+
+// ExplainQL is like MatchQL except that it returns a trace of the evaluation,
+// err is the query parse error or the evaluation error of the whole statement
+func (c Context) ExplainQL(query string) (explained *Explanation, err error) {
+	var q *Query
+	if q, err = CompileQL(query); err == nil {
+		explained = q.Explain(c)
+		err = explained.Err
+	}
+	return
+}
+
+// Explain is like Match except that it returns a trace of the evaluation
+func (q *Query) Explain(c Context) (explained *Explanation) {
+	if q.unbound {
+		explained = &Explanation{Expression: q.stmnt.Expression, Err: errUnboundQuery}
+		return
+	}
+	explained = c.explainQueryExpression(q, q.stmnt.Expression)
+	return
+}
+
+func (c Context) explainQueryExpression(q *Query, expr *cql.Expression) (explained *Explanation) {
+	explained = &Explanation{Expression: expr}
+	switch {
+
+	case expr.Condition != nil:
+		cond := expr.Condition
+		switch strings.ToUpper(cond.Type) {
+		case "NOT":
+			explained.Right = c.explainQueryExpression(q, cond.Right)
+			explained.Matched, explained.Err = !explained.Right.Matched, explained.Right.Err
+		case "OR", "AND":
+			explained.Left = c.explainQueryExpression(q, cond.Left)
+			explained.Matched, explained.Err = explained.Left.Matched, explained.Left.Err
+			if explained.Err == nil && explained.Matched == (strings.ToUpper(cond.Type) == "AND") {
+				explained.Right = c.explainQueryExpression(q, cond.Right)
+				explained.Matched, explained.Err = explained.Right.Matched, explained.Right.Err
+			} else {
+				explained.Right = skipQueryExpression(cond.Right)
+			}
+		}
+
+	case expr.Operation != nil:
+		op := expr.Operation
+		traced := q.tracing()
+		explained.Matched, explained.Err = c.processQueryOperation(traced, op)
+		explained.LeftValue = queryOperand(traced, op.Left)
+		if op.Right != nil {
+			explained.RightValue = queryOperand(traced, op.Right)
+		}
+
+	case expr.Quantifier != nil:
+		quant := expr.Quantifier
+		explained.LeftValue, _ = c.DeepValue(quant.ContextKey)
+		explained.Matched, explained.Err = c.quantifyQuery(q, quant, func(ctx Context, scoped *Query) (bool, error) {
+			item := ctx.explainQueryExpression(scoped, quant.Expression)
+			explained.Items = append(explained.Items, item)
+			return item.Matched, item.Err
+		})
+
+	}
+	if explained.Err != nil {
+		explained.Matched = false
+	}
+	return
+}
+
+func skipQueryExpression(expr *cql.Expression) (explained *Explanation) {
+	explained = &Explanation{Expression: expr, Skipped: true}
+	if cond := expr.Condition; cond != nil {
+		if cond.Left != nil {
+			explained.Left = skipQueryExpression(cond.Left)
+		}
+		explained.Right = skipQueryExpression(cond.Right)
+	}
+	return
+}
+
+// queryOperand returns the value of the given operand, as resolved by the
+// evaluation of the traced Query (see Query.tracing), which is nil for
+// operands not resolved, and lists are returned item by item
+func queryOperand(traced *Query, v *cql.Value) (value interface{}) {
+	switch {
+	case v.ContextKey != nil, v.Call != nil, v.Time != nil, v.Duration != nil, v.Arithmetic != nil, v.Self != nil:
+		value = traced.resolved[v]
+	case v.String != nil:
+		value = *v.String
+	case v.Regexp != nil:
+		value = *v.Regexp
+	case v.Int != nil:
+		value = *v.Int
+	case v.Float != nil:
+		value = *v.Float
+	case v.Bool != nil:
+		value = bool(*v.Bool)
+	case v.List != nil:
+		list := make([]interface{}, len(v.List))
+		for idx, item := range v.List {
+			list[idx] = queryOperand(traced, item)
+		}
+		value = list
+	case v.Placeholder != nil:
+		value = *v.Placeholder
+	}
+	return
+}
+
+// Pretty returns a human-readable, indented rendering of the evaluation trace
+//
+// Example:
+//
+//	OR => true
+//	  AND => false
+//	    .Type == 'page' => true
+//	      .Type: "page"
+//	    .Weight >= 10 => false
+//	      .Weight: 5
+//	  NOT => true
+//	    .Other IN (1, .Weight) => false
+//	      .Other: 3
+//	      .Weight: 5
+func (e *Explanation) Pretty() (refined string) {
+	var buf strings.Builder
+	e.pretty(&buf, "")
+	refined = buf.String()
+	return
+}
+
+func (e *Explanation) pretty(buf *strings.Builder, indent string) {
+	var result string
+	switch {
+	case e.Skipped:
+		result = "skipped"
+	case e.Err != nil:
+		result = "error: " + e.Err.Error()
+	default:
+		result = fmt.Sprintf("%v", e.Matched)
+	}
+
+	switch {
+
+	case e.Expression.Condition != nil:
+		buf.WriteString(fmt.Sprintf("%v%v => %v\n", indent, strings.ToUpper(e.Expression.Condition.Type), result))
+		if e.Left != nil {
+			e.Left.pretty(buf, indent+"  ")
+		}
+		if e.Right != nil {
+			e.Right.pretty(buf, indent+"  ")
+		}
+
+	case e.Expression.Operation != nil:
+		op := e.Expression.Operation
+		label := cql.FormatValue(op.Left)
+		if op.Type != "" {
+			label += " " + string(op.Type)
+		}
+		if op.Right != nil {
+			label += " " + cql.FormatValue(op.Right)
+		}
+		buf.WriteString(fmt.Sprintf("%v%v => %v\n", indent, label, result))
+		if !e.Skipped {
+			buf.WriteString(fmt.Sprintf("%v  %v: %#v\n", indent, cql.FormatValue(op.Left), e.LeftValue))
+			if op.Right != nil && explainResolved(op.Right) {
+				buf.WriteString(fmt.Sprintf("%v  %v: %#v\n", indent, cql.FormatValue(op.Right), e.RightValue))
+			} else if list, ok := e.RightValue.([]interface{}); ok && op.Right != nil {
+				for idx, item := range op.Right.List {
+					if explainResolved(item) && idx < len(list) {
+						buf.WriteString(fmt.Sprintf("%v  %v: %#v\n", indent, cql.FormatValue(item), list[idx]))
+					}
+				}
+			}
+		}
+
+	case e.Expression.Quantifier != nil:
+		quant := e.Expression.Quantifier
+		buf.WriteString(fmt.Sprintf("%v%v .%v => %v\n", indent, quant.Type, quant.ContextKey, result))
+		if !e.Skipped {
+			buf.WriteString(fmt.Sprintf("%v  .%v: %#v\n", indent, quant.ContextKey, e.LeftValue))
+		}
+		items, _ := querySlice(e.LeftValue)
+		for idx, item := range e.Items {
+			if idx < len(items) {
+				buf.WriteString(fmt.Sprintf("%v  [%d]: %#v\n", indent, idx, items[idx]))
+			}
+			item.pretty(buf, indent+"    ")
+		}
+
+	}
+}
+
+// explainResolved reports whether the given operand is resolved during the
+// evaluation, as opposed to a literal value
+func explainResolved(v *cql.Value) bool {
+	return v.ContextKey != nil || v.Call != nil || v.Arithmetic != nil || v.Self != nil
+}
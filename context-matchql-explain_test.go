@@ -0,0 +1,139 @@
+// Copyright (c) 2024  The Go-CoreLibs Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package context
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/go-corelibs/context/cql"
+)
+
+func TestExplainQL(t *testing.T) {
+	Convey("ExplainQL", t, func() {
+		ctx := Context{"Type": "page", "Weight": 5, "Other": 3}
+
+		explained, err := ctx.ExplainQL(`.Type == 'page' AND .Weight >= 10 OR NOT .Other IN (1, .Weight)`)
+		So(err, ShouldBeNil)
+		So(explained.Matched, ShouldBeTrue)
+		So(explained.Expression.Condition.Type, ShouldEqual, "OR")
+		So(explained.Left.Matched, ShouldBeFalse)
+		So(explained.Left.Left.LeftValue, ShouldEqual, "page")
+		So(explained.Left.Left.RightValue, ShouldEqual, "page")
+		So(explained.Left.Right.LeftValue, ShouldEqual, 5)
+		So(explained.Right.Left, ShouldBeNil)
+		So(explained.Right.Right.RightValue, ShouldEqual, []interface{}{1, 5})
+		So(explained.Pretty(), ShouldEqual, `OR => true
+  AND => false
+    .Type == 'page' => true
+      .Type: "page"
+    .Weight >= 10 => false
+      .Weight: 5
+  NOT => true
+    .Other IN (1, .Weight) => false
+      .Other: 3
+      .Weight: 5
+`)
+
+		explained, err = ctx.ExplainQL(`.Weight =~ m/x/ AND .Type =~ 'p'`)
+		So(err, ShouldNotBeNil)
+		So(explained.Matched, ShouldBeFalse)
+		So(explained.Err, ShouldEqual, err)
+		So(explained.Left.Err, ShouldEqual, err)
+		So(explained.Right.Skipped, ShouldBeTrue)
+		So(explained.Pretty(), ShouldEqual, `AND => error: page.Weight is of type int, expected string
+  .Weight =~ m/x/ => error: page.Weight is of type int, expected string
+    .Weight: 5
+  .Type =~ 'p' => skipped
+`)
+
+		explained, err = Context{"Path": "a/b", "Weight": 5, "Other": 3, "S": "text"}.ExplainQL(`.Path =~ m!a/b! AND .Weight * 2 + .Other > len(.S)`)
+		So(err, ShouldBeNil)
+		So(explained.Pretty(), ShouldEqual, `AND => true
+  .Path =~ m!a/b! => true
+    .Path: "a/b"
+  .Weight * 2 + .Other > len(.S) => true
+    .Weight * 2 + .Other: 13
+    len(.S): 4
+`)
+
+		explained, err = ctx.ExplainQL(`.Type ==`)
+		So(err, ShouldNotBeNil)
+		So(explained, ShouldBeNil)
+	})
+
+	Convey("Operands are resolved once", t, func() {
+		var calls int
+		defer UnregisterQueryFunc("testCounted")
+		So(RegisterQueryFunc("testCounted", cql.Signature{Result: cql.NumberType}, func(ctx Context, args ...interface{}) (interface{}, error) {
+			calls += 1
+			return calls, nil
+		}), ShouldBeNil)
+
+		explained, err := Context{}.ExplainQL(`testCounted() == 1`)
+		So(err, ShouldBeNil)
+		So(calls, ShouldEqual, 1)
+		So(explained.Matched, ShouldBeTrue)
+		So(explained.LeftValue, ShouldEqual, 1)
+
+		// the time traced is the time compared
+		ticks := 0
+		q := MustCompileQL(`.Published < now() - 1h`).WithClock(func() time.Time {
+			ticks += 1
+			return time.Date(2024, 1, 1, ticks, 0, 0, 0, time.UTC)
+		})
+		explained = q.Explain(Context{"Published": time.Date(2024, 1, 1, 0, 30, 0, 0, time.UTC)})
+		So(explained.Err, ShouldBeNil)
+		So(ticks, ShouldEqual, 1)
+		So(explained.Matched, ShouldBeFalse)
+		So(explained.RightValue, ShouldEqual, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	})
+
+	Convey("Quantifiers", t, func() {
+		ctx := Context{"Tags": []interface{}{"go", "query", "cql"}, "Authors": Contexts{{"Name": "Ann"}, {"Name": 1}}}
+
+		explained, err := ctx.ExplainQL(`ANY .Tags (. == 'query')`)
+		So(err, ShouldBeNil)
+		So(explained.Matched, ShouldBeTrue)
+		So(explained.Items, ShouldHaveLength, 2)
+		So(explained.Items[0].Matched, ShouldBeFalse)
+		So(explained.Items[1].Matched, ShouldBeTrue)
+		So(explained.Items[1].LeftValue, ShouldEqual, "query")
+		So(explained.Items[1].Expression, ShouldEqual, explained.Expression.Quantifier.Expression)
+		So(explained.Pretty(), ShouldEqual, `ANY .Tags => true
+  .Tags: []interface {}{"go", "query", "cql"}
+  [0]: "go"
+    . == 'query' => false
+      .: "go"
+  [1]: "query"
+    . == 'query' => true
+      .: "query"
+`)
+
+		explained, err = ctx.ExplainQL(`ALL .Authors (.Name =~ m/^A/)`)
+		So(err, ShouldNotBeNil)
+		So(explained.Items, ShouldHaveLength, 2)
+		So(explained.Items[0].Matched, ShouldBeTrue)
+		So(explained.Items[1].Err, ShouldNotBeNil)
+		So(explained.Err.Error(), ShouldEqual, "page.Authors[1]: "+explained.Items[1].Err.Error())
+
+		explained, err = ctx.ExplainQL(`.Tags == nil AND ALL .Tags (. == 'go')`)
+		So(err, ShouldBeNil)
+		So(explained.Right.Skipped, ShouldBeTrue)
+		So(explained.Right.Items, ShouldBeEmpty)
+	})
+}
@@ -0,0 +1,356 @@
+// Copyright (c) 2024  The Go-CoreLibs Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package context
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-corelibs/context/cql"
+)
+
+// queryArg is a resolved function argument, label identifies the argument in
+// error messages
+type queryArg struct {
+	label string
+	value interface{}
+}
+
+// queryFunc is the implementation of a built-in query function
+type queryFunc func(q *Query, args []queryArg) (value interface{}, err error)
+
+// queryBuiltins are the implementations of the functions listed by the cql
+// package, see MatchQL for their descriptions
+var queryBuiltins = map[string]queryFunc{
+	"contains":   queryContains,
+	"startsWith": queryStartsWith,
+	"endsWith":   queryEndsWith,
+	"len":        queryLen,
+	"lower":      queryLower,
+	"upper":      queryUpper,
+	"now":        queryNow,
+}
+
+// QueryFunc is a Go function callable from queries, see RegisterQueryFunc.
+// The ctx is the Context being matched and the args are the resolved argument
+// values, in the order given by the query and checked against the Signature
+type QueryFunc func(ctx Context, args ...interface{}) (value interface{}, err error)
+
+var (
+	_queryFuncs     = make(map[string]QueryFunc)
+	_queryFuncsLock = &sync.RWMutex{}
+)
+
+// RegisterQueryFunc adds the named Go function to the query language, for use
+// the same as the built-in functions (see MatchQL), and registers its
+// Signature with the cql package so that Compile checks the number and types
+// of the arguments of any calls. Functions returning a cql.BoolType can be
+// used as predicates on their own
+//
+// The arguments are checked again during evaluation, as the types of context
+// key values are only known then, and the value returned must be of the
+// Signature Result type
+//
+// Example:
+//
+//	err := context.RegisterQueryFunc("visibleIn", cql.Signature{
+//	    Args:   []cql.Type{cql.StringType},
+//	    Result: cql.BoolType,
+//	}, func(ctx context.Context, args ...interface{}) (interface{}, error) {
+//	    return ctx.String("Locale") == args[0].(string), nil
+//	})
+//	// ...
+//	matched, err := page.MatchQL(`visibleIn('en') AND NOT .Draft == true`)
+func RegisterQueryFunc(name string, sig cql.Signature, fn QueryFunc) (err error) {
+	if fn == nil {
+		err = fmt.Errorf("%v: missing function", name)
+		return
+	}
+	_queryFuncsLock.Lock()
+	defer _queryFuncsLock.Unlock()
+	if err = cql.RegisterFunc(name, sig); err == nil {
+		_queryFuncs[name] = fn
+	}
+	return
+}
+
+// UnregisterQueryFunc removes the named function added by RegisterQueryFunc,
+// returning false when it was not registered. Compiled queries calling the
+// function report an unknown function error when evaluated
+func UnregisterQueryFunc(name string) (ok bool) {
+	_queryFuncsLock.Lock()
+	defer _queryFuncsLock.Unlock()
+	if _, ok = _queryFuncs[name]; ok {
+		delete(_queryFuncs, name)
+		cql.UnregisterFunc(name)
+	}
+	return
+}
+
+// queryLabel returns the name used for the given operand in error messages,
+// context keys are prefixed with "page." and function calls include the
+// labels of their arguments
+func queryLabel(v *cql.Value) (label string) {
+	switch {
+	case v.ContextKey != nil:
+		label = "page." + *v.ContextKey
+	case v.Call != nil:
+		args := make([]string, len(v.Call.Args))
+		for idx, arg := range v.Call.Args {
+			args[idx] = queryLabel(arg)
+		}
+		label = v.Call.Name + "(" + strings.Join(args, ", ") + ")"
+	case v.String != nil:
+		label = cql.QuoteString(*v.String)
+	case v.Regexp != nil:
+		label = cql.QuoteRegexp(*v.Regexp)
+	case v.Int != nil:
+		label = fmt.Sprint(*v.Int)
+	case v.Float != nil:
+		label = fmt.Sprint(*v.Float)
+	case v.Bool != nil:
+		label = fmt.Sprint(bool(*v.Bool))
+	case v.Nil != nil:
+		label = "nil"
+	case v.Time != nil:
+		label = *v.Time
+	case v.Duration != nil:
+		label = *v.Duration
+	case v.Arithmetic != nil:
+		label = queryLabel(v.Arithmetic.Left) + " " + v.Arithmetic.Operator + " " + queryLabel(v.Arithmetic.Right)
+	case v.Placeholder != nil:
+		label = *v.Placeholder
+	case v.Self != nil:
+		label = "."
+	}
+	return
+}
+
+// queryResolve returns the value of the given operand, context keys are looked
+// up, function calls are evaluated and regexps are returned as their pattern
+// strings. The Self value is the current item of an ANY or ALL expression, or
+// this context outside of them. Time and duration literals are returned as time.Time and
+// time.Duration values and arithmetic is applied. found is false only for
+// missing context keys
+func (c Context) queryResolve(q *Query, v *cql.Value) (value interface{}, found bool, err error) {
+	found = true
+	switch {
+	case v.ContextKey != nil:
+		value, found = c.DeepValue(*v.ContextKey)
+	case v.Call != nil:
+		value, err = c.queryCall(q, v.Call)
+	case v.String != nil:
+		value = *v.String
+	case v.Regexp != nil:
+		value = *v.Regexp
+	case v.Int != nil:
+		value = *v.Int
+	case v.Float != nil:
+		value = *v.Float
+	case v.Bool != nil:
+		value = bool(*v.Bool)
+	case v.Nil != nil:
+		value = nil
+	case v.Time != nil:
+		value, err = cql.ParseTime(*v.Time)
+	case v.Duration != nil:
+		value, err = cql.ParseDuration(*v.Duration)
+	case v.Arithmetic != nil:
+		value, err = c.queryArithmetic(q, v.Arithmetic)
+	case v.Self != nil:
+		if value = c; q.scoped {
+			value = q.self
+		}
+	case v.List != nil:
+		list := make([]interface{}, len(v.List))
+		for idx, item := range v.List {
+			if list[idx], _, err = c.queryResolve(q, item); err != nil {
+				return
+			}
+		}
+		value = list
+	case v.Placeholder != nil:
+		err = fmt.Errorf("unbound placeholder %v", *v.Placeholder)
+	}
+	if err == nil && q.resolved != nil {
+		q.resolved[v] = value
+	}
+	return
+}
+
+// queryCall evaluates the given function call with its arguments resolved
+// against this context
+func (c Context) queryCall(q *Query, call *cql.Call) (value interface{}, err error) {
+	args := make([]queryArg, len(call.Args))
+	for idx, arg := range call.Args {
+		args[idx].label = queryLabel(arg)
+		if args[idx].value, _, err = c.queryResolve(q, arg); err != nil {
+			return
+		}
+	}
+
+	if builtin, ok := queryBuiltins[call.Name]; ok {
+		value, err = builtin(q, args)
+		return
+	}
+
+	_queryFuncsLock.RLock()
+	fn, ok := _queryFuncs[call.Name]
+	_queryFuncsLock.RUnlock()
+	sig, known := cql.LookupFunc(call.Name)
+	if !ok || !known {
+		err = fmt.Errorf("unknown function %q", call.Name)
+		return
+	} else if len(args) != len(sig.Args) {
+		err = fmt.Errorf("%v expects %d argument(s), found %d", call.Name, len(sig.Args), len(args))
+		return
+	}
+
+	inputs := make([]interface{}, len(args))
+	for idx, arg := range args {
+		if err = queryCheckType(arg, sig.Args[idx]); err != nil {
+			return
+		}
+		inputs[idx] = arg.value
+	}
+	if value, err = fn(c, inputs...); err != nil {
+		err = fmt.Errorf("%v: %w", call.Name, err)
+		return
+	}
+	result := queryArg{label: queryLabel(&cql.Value{Call: call}), value: value}
+	if queryCheckType(result, sig.Result) != nil {
+		value, err = nil, fmt.Errorf("%v returned %T, expected %v", result.label, value, sig.Result)
+	}
+	return
+}
+
+// queryCheckType returns an error if the value of the argument given is not of
+// the expected type, any numeric type is a cql.NumberType and any slice or
+// array a cql.ListType
+func queryCheckType(arg queryArg, expected cql.Type) (err error) {
+	var ok bool
+	switch expected {
+	case cql.AnyType:
+		ok = true
+	case cql.StringType:
+		_, ok = arg.value.(string)
+	case cql.NumberType:
+		_, _, _, ok = queryNumber(arg.value)
+	case cql.BoolType:
+		_, ok = arg.value.(bool)
+	case cql.ListType:
+		_, ok = querySlice(arg.value)
+	case cql.TimeType:
+		_, ok = arg.value.(time.Time)
+	case cql.DurationType:
+		_, ok = arg.value.(time.Duration)
+	}
+	if !ok {
+		err = fmt.Errorf("%v is of type %T, expected %v", arg.label, arg.value, expected)
+	}
+	return
+}
+
+// queryString returns the string value of the argument given
+func queryString(arg queryArg) (s string, err error) {
+	var ok bool
+	if s, ok = arg.value.(string); !ok {
+		err = fmt.Errorf("%v is of type %T, expected string", arg.label, arg.value)
+	}
+	return
+}
+
+func queryContains(_ *Query, args []queryArg) (value interface{}, err error) {
+	if list, ok := querySlice(args[0].value); ok {
+		for _, item := range list {
+			if same, e := queryValuesEqual(item, args[1].value); e == nil && same {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	s, ok := args[0].value.(string)
+	if !ok {
+		err = fmt.Errorf("%v is of type %T, expected string or list", args[0].label, args[0].value)
+		return
+	}
+	var sub string
+	if sub, err = queryString(args[1]); err == nil {
+		value = strings.Contains(s, sub)
+	}
+	return
+}
+
+func queryStartsWith(_ *Query, args []queryArg) (value interface{}, err error) {
+	var s, prefix string
+	if s, err = queryString(args[0]); err == nil {
+		if prefix, err = queryString(args[1]); err == nil {
+			value = strings.HasPrefix(s, prefix)
+		}
+	}
+	return
+}
+
+func queryEndsWith(_ *Query, args []queryArg) (value interface{}, err error) {
+	var s, suffix string
+	if s, err = queryString(args[0]); err == nil {
+		if suffix, err = queryString(args[1]); err == nil {
+			value = strings.HasSuffix(s, suffix)
+		}
+	}
+	return
+}
+
+func queryLen(_ *Query, args []queryArg) (value interface{}, err error) {
+	if s, ok := args[0].value.(string); ok {
+		value = utf8.RuneCountInString(s)
+		return
+	}
+	rv := reflect.ValueOf(args[0].value)
+	if rv.IsValid() {
+		switch rv.Kind() {
+		case reflect.Slice, reflect.Array, reflect.Map:
+			value = rv.Len()
+			return
+		}
+	}
+	err = fmt.Errorf("%v is of type %T, expected string, list or map", args[0].label, args[0].value)
+	return
+}
+
+func queryLower(_ *Query, args []queryArg) (value interface{}, err error) {
+	var s string
+	if s, err = queryString(args[0]); err == nil {
+		value = strings.ToLower(s)
+	}
+	return
+}
+
+func queryUpper(_ *Query, args []queryArg) (value interface{}, err error) {
+	var s string
+	if s, err = queryString(args[0]); err == nil {
+		value = strings.ToUpper(s)
+	}
+	return
+}
+
+func queryNow(q *Query, _ []queryArg) (value interface{}, err error) {
+	value = q.now()
+	return
+}
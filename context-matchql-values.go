@@ -0,0 +1,154 @@
+// Copyright (c) 2024  The Go-CoreLibs Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package context
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+
+	"github.com/maruel/natural"
+
+	"github.com/go-corelibs/values"
+)
+
+// queryNumber reports the numeric value of the given input, isInt is true when
+// the input is one of the integer types and ok is false when the input is not
+// a number at all
+//
+// Strings are not converted, JSON, TOML and YAML all produce numeric types for
+// numeric values and so the only coercion necessary is between the int, int64
+// and float64 variants the different parsers prefer
+//
+// Unsigned integers greater than math.MaxInt64 do not fit an int64 and are
+// reported as floats instead
+func queryNumber(input interface{}) (i int64, f float64, isInt, ok bool) {
+	rv := reflect.ValueOf(input)
+	if !rv.IsValid() {
+		return
+	}
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i = rv.Int()
+		f = float64(i)
+		isInt, ok = true, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u := rv.Uint()
+		f = float64(u)
+		if u <= math.MaxInt64 {
+			i = int64(u)
+			isInt = true
+		} else {
+			i = math.MaxInt64
+		}
+		ok = true
+	case reflect.Float32, reflect.Float64:
+		f = rv.Float()
+		i = int64(f)
+		ok = true
+	}
+	return
+}
+
+// querySlice returns the items of the given input if it is a slice or array,
+// byte slices are not considered lists
+func querySlice(input interface{}) (list []interface{}, ok bool) {
+	if _, isBytes := input.([]byte); isBytes {
+		return
+	}
+	rv := reflect.ValueOf(input)
+	if !rv.IsValid() {
+		return
+	}
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		ok = true
+		list = make([]interface{}, rv.Len())
+		for idx := 0; idx < rv.Len(); idx++ {
+			list[idx] = rv.Index(idx).Interface()
+		}
+	}
+	return
+}
+
+// queryValuesEqual compares two arbitrary values, treating nil (and missing)
+// values as equal only to other nil values, comparing numbers by value
+// regardless of their specific Go types and time.Time values by instant, all
+// other cases are deferred to values.Compare
+func queryValuesEqual(a, b interface{}) (same bool, err error) {
+	aNil, bNil := values.IsNil(a), values.IsNil(b)
+	if aNil || bNil {
+		same = aNil && bNil
+		return
+	}
+	if at, ok := a.(time.Time); ok {
+		if bt, ok := b.(time.Time); ok {
+			same = at.Equal(bt)
+			return
+		}
+	}
+	if ai, af, aIsInt, ok := queryNumber(a); ok {
+		if bi, bf, bIsInt, ok := queryNumber(b); ok {
+			if aIsInt && bIsInt {
+				same = ai == bi
+			} else {
+				same = af == bf
+			}
+			return
+		}
+	}
+	same, err = values.Compare(a, b)
+	return
+}
+
+// queryValuesOrder returns -1, 0 or 1 when a is less than, equal to or greater
+// than b. Numbers are compared by value, strings in natural order and
+// time.Time values chronologically, all other combinations are an error
+func queryValuesOrder(a, b interface{}) (order int, err error) {
+	if ai, af, aIsInt, ok := queryNumber(a); ok {
+		if bi, bf, bIsInt, ok := queryNumber(b); ok {
+			switch {
+			case aIsInt && bIsInt && ai < bi, !(aIsInt && bIsInt) && af < bf:
+				order = -1
+			case aIsInt && bIsInt && ai > bi, !(aIsInt && bIsInt) && af > bf:
+				order = 1
+			}
+			return
+		}
+	}
+
+	switch at := a.(type) {
+	case string:
+		if bt, ok := b.(string); ok {
+			switch {
+			case at == bt:
+			case natural.Less(at, bt):
+				order = -1
+			default:
+				order = 1
+			}
+			return
+		}
+	case time.Time:
+		if bt, ok := b.(time.Time); ok {
+			order = at.Compare(bt)
+			return
+		}
+	}
+
+	err = fmt.Errorf("cannot order %T with %T", a, b)
+	return
+}
@@ -16,71 +16,279 @@ package context
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/go-corelibs/context/cql"
-	"github.com/go-corelibs/regexps"
 	"github.com/go-corelibs/values"
 )
 
-var _rxc = regexps.NewCache()
-
 // MatchQL checks if the given context query statement matches this context
+//
+// Operations supported:
+//
+//	| operator    | description                                              |
+//	|-------------|----------------------------------------------------------|
+//	| ==          | value equality, regexp values must match the whole value |
+//	| !=          | negated ==                                               |
+//	| ==*         | case-insensitive ==, using Unicode case folding          |
+//	| !=*         | negated ==*                                              |
+//	| =~          | regexp (or string pattern) matches within the value      |
+//	| !~          | negated =~                                               |
+//	| <, <=       | less than, less than or equal to                         |
+//	| >, >=       | greater than, greater than or equal to                   |
+//	| IN          | value (or any of a list of values) is within a list      |
+//	| IS NULL     | key is present and the value is nil                      |
+//	| IS NOT NULL | key is present and the value is not nil (see Has)        |
+//
+// Functions supported:
+//
+//	| function         | description                                             |
+//	|------------------|---------------------------------------------------------|
+//	| contains(v, s)   | string v contains s, or list v has an item equal to s   |
+//	| startsWith(v, s) | string v starts with s                                  |
+//	| endsWith(v, s)   | string v ends with s                                    |
+//	| len(v)           | length of a string (in characters), list or map         |
+//	| lower(v)         | string v in lower case                                  |
+//	| upper(v)         | string v in upper case                                  |
+//	| now()            | the current time, see Query.WithClock                   |
+//
+// Function calls may be used in place of a context key on either side of an
+// operation and the arguments may be context keys, literals or other calls.
+// Functions returning a bool may also be used on their own as a predicate,
+// such as: contains(.Tags, 'go') AND NOT startsWith(.Title, 'Draft'). Further
+// functions can be added with RegisterQueryFunc
+//
+// Numeric values are compared by value regardless of the specific int, int64
+// or float64 types produced by the JSON, TOML and YAML parsers and comparing
+// with nil is true for both nil values and missing keys
+//
+// The right-hand side of IN is either a literal list, which may include context
+// keys, or a single context key, any context keys with list values are
+// expanded in place. When the left-hand value is itself a list, IN matches if
+// any of its items are present. Regexp items must match a whole value
+//
+// Context keys may be deep key paths, such as .Author.Name or .Items[0].Title,
+// see DeepValue for the details
+//
+// Unlike == nil, neither IS NULL nor IS NOT NULL match missing keys
+//
+// Ordering comparisons are defined for numbers, strings (in natural order) and
+// time.Time values, anything else is an error
+//
+// Either side of an operation may be an arithmetic expression using +, -, *,
+// / and %, with *, / and % applied before + and - and otherwise from left to
+// right. There is no grouping within arithmetic expressions and list items and
+// function arguments are single values. Integers of any size are used as
+// int64 values and the result is a float64 when either side is a float.
+// Integer division truncates and % of floats is math.Mod. Adding two strings
+// concatenates them and so + never converts strings to numbers, while -, *, /
+// and % convert numeric strings the same as the Int64 and Float64 accessors.
+// Dividing by zero and operands of any other types are errors:
+//
+//	.Stock - .Reserved > 0 AND .Price * 1.2 <= .Budget
+//	.First + ' ' + .Last == 'Ann Smith' AND .Count % 2 == 0
+//
+// Time literals are either date-only, such as 2024-01-02 (midnight UTC), or
+// RFC 3339 timestamps, such as 2024-01-02T15:04:05Z, and are compared with
+// time.Time values. Duration literals use the time.ParseDuration units plus d
+// for days, such as 7d or 36h, and are compared with time.Duration values.
+// Durations can be added to or subtracted from times and each other, and
+// subtracting two times gives the duration between them:
+//
+//	.Published > now() - 30d AND .Updated - .Published < 1d12h
+//
+// The ANY and ALL quantifiers evaluate an expression with each item of a list
+// as the current context, matching when any or all of the items match. Within
+// the expression, context keys refer to the keys of the item and . refers to
+// the item itself, which is how lists of plain values are matched. The list
+// may be a Contexts, []Context, []map[string]interface{} or any other slice,
+// missing keys and nil values are empty lists and ALL matches an empty list:
+//
+//	ANY .Authors (.Name == 'Ann') AND ALL .Tags (. =~ m/^[a-z]+$/)
+//
+// Conditions are evaluated left to right and stop as soon as the result is
+// known: the right side of an AND is skipped when the left side is false and
+// the right side of an OR is skipped when the left side is true. The first
+// error encountered stops the evaluation and is returned with matched false,
+// so errors in skipped expressions are never reported
+//
+// MatchQL parses the query on each call, use CompileQL to prepare a Query once
+// for repeated use and CompileQLWithLimits for queries from untrusted sources.
+// Compiled regular expressions are cached, see SetRegexpCacheSize
 func (c Context) MatchQL(query string) (matched bool, err error) {
-	var stmnt *cql.Statement
-	var pErr *cql.ParseError
-	if stmnt, pErr = cql.Compile(query); pErr != nil {
-		err = error(pErr)
-		return
+	var q *Query
+	if q, err = CompileQL(query); err == nil {
+		matched, err = q.Match(c)
+	}
+	return
+}
+
+// MatchQLArgs is like MatchQL with the query placeholders bound to the
+// arguments given, see Query.Bind
+func (c Context) MatchQLArgs(query string, args ...interface{}) (matched bool, err error) {
+	var q *Query
+	if q, err = CompileQL(query); err == nil {
+		if q, err = q.Bind(args...); err == nil {
+			matched, err = q.Match(c)
+		}
 	}
-	stmnt = stmnt.Render()
-	matched, err = c.processQueryExpression(stmnt.Expression)
 	return
 }
 
-func (c Context) processQueryExpression(expr *cql.Expression) (matched bool, err error) {
+func (c Context) processQueryExpression(q *Query, expr *cql.Expression) (matched bool, err error) {
 	switch {
 
 	case expr.Condition != nil:
-		matched, err = c.processQueryCondition(expr.Condition)
+		matched, err = c.processQueryCondition(q, expr.Condition)
 
 	case expr.Operation != nil:
-		matched, err = c.processQueryOperation(expr.Operation)
+		matched, err = c.processQueryOperation(q, expr.Operation)
+
+	case expr.Quantifier != nil:
+		matched, err = c.processQueryQuantifier(q, expr.Quantifier)
 
 	}
 	return
 }
 
-func (c Context) processQueryCondition(cond *cql.Condition) (matched bool, err error) {
-	if cond.Left != nil && cond.Right != nil {
-		var leftMatch, rightMatch bool
-		if leftMatch, err = c.processQueryExpression(cond.Left); err != nil {
+// processQueryQuantifier evaluates the quantified expression with each item of
+// the list value as the current Context, stopping as soon as the result is
+// known
+func (c Context) processQueryQuantifier(q *Query, quant *cql.Quantifier) (matched bool, err error) {
+	matched, err = c.quantifyQuery(q, quant, func(ctx Context, scoped *Query) (bool, error) {
+		return ctx.processQueryExpression(scoped, quant.Expression)
+	})
+	return
+}
+
+// quantifyQuery calls process with the Context and scoped Query of each item
+// of the quantified list in turn, stopping as soon as the result is known
+func (c Context) quantifyQuery(q *Query, quant *cql.Quantifier, process func(ctx Context, scoped *Query) (bool, error)) (matched bool, err error) {
+	var items []interface{}
+	if items, err = c.queryItems(quant.ContextKey); err != nil {
+		return
+	}
+	all := quant.Type == "ALL"
+	matched = all
+	for idx, item := range items {
+		ctx, self := queryItemContext(item)
+		var m bool
+		if m, err = process(ctx, q.scope(self)); err != nil {
+			matched, err = false, fmt.Errorf("page.%v[%d]: %w", quant.ContextKey, idx, err)
 			return
-		}
-		if rightMatch, err = c.processQueryExpression(cond.Right); err != nil {
+		} else if m != all {
+			// ANY found a match or ALL found a mismatch
+			matched = m
 			return
 		}
-		switch strings.ToUpper(cond.Type) {
-		case "OR":
-			matched = leftMatch || rightMatch
-		case "AND":
-			matched = leftMatch && rightMatch
+	}
+	return
+}
+
+// queryItems returns the items of the list value of the given context key,
+// missing keys and nil values have no items
+func (c Context) queryItems(key string) (items []interface{}, err error) {
+	value, _ := c.DeepValue(key)
+	if values.IsNil(value) {
+		return
+	}
+	var ok bool
+	if items, ok = querySlice(value); !ok {
+		err = fmt.Errorf("page.%v is of type %T, expected list", key, value)
+	}
+	return
+}
+
+// queryItemContext returns the Context to evaluate a quantified expression
+// with for the given list item and the value of the item itself. Context and
+// map items are used directly, the keys of any other item are all missing
+func queryItemContext(item interface{}) (ctx Context, self interface{}) {
+	switch t := item.(type) {
+	case Context:
+		ctx, self = t, t
+	case map[string]interface{}:
+		ctx = Context(t)
+		self = ctx
+	default:
+		ctx, self = Context{}, item
+	}
+	return
+}
+
+func (c Context) processQueryCondition(q *Query, cond *cql.Condition) (matched bool, err error) {
+	switch strings.ToUpper(cond.Type) {
+
+	case "NOT":
+		if cond.Right != nil {
+			if matched, err = c.processQueryExpression(q, cond.Right); err == nil {
+				matched = !matched
+			}
 		}
+
+	case "OR":
+		if cond.Left != nil && cond.Right != nil {
+			if matched, err = c.processQueryExpression(q, cond.Left); err == nil && !matched {
+				matched, err = c.processQueryExpression(q, cond.Right)
+			}
+		}
+
+	case "AND":
+		if cond.Left != nil && cond.Right != nil {
+			if matched, err = c.processQueryExpression(q, cond.Left); err == nil && matched {
+				matched, err = c.processQueryExpression(q, cond.Right)
+			}
+		}
+
 	}
 	return
 }
 
-func (c Context) processQueryOperation(op *cql.Operation) (matched bool, err error) {
+func (c Context) processQueryOperation(q *Query, op *cql.Operation) (matched bool, err error) {
+	label := queryLabel(op.Left)
+	var lValue interface{}
+	var found bool
+	if lValue, found, err = c.queryResolve(q, op.Left); err != nil {
+		return
+	}
+
 	switch op.Type {
 
-	case "==":
-		matched, err = c.processQueryOperationEquals(*op.Left, op.Right)
+	case "":
+		// function call predicate
+		var ok bool
+		if matched, ok = lValue.(bool); !ok {
+			err = fmt.Errorf("%v is of type %T, expected bool", label, lValue)
+		}
 
-	case "!=":
-		if matched, err = c.processQueryOperationEquals(*op.Left, op.Right); err == nil {
+	case "==", "==*":
+		matched, err = c.processQueryOperationEquals(q, label, lValue, op.Right, op.Type == "==*")
+
+	case "!=", "!=*":
+		if matched, err = c.processQueryOperationEquals(q, label, lValue, op.Right, op.Type == "!=*"); err == nil {
 			matched = !matched
 		}
 
+	case "=~":
+		matched, err = c.processQueryOperationMatches(q, label, lValue, op.Right)
+
+	case "!~":
+		if matched, err = c.processQueryOperationMatches(q, label, lValue, op.Right); err == nil {
+			matched = !matched
+		}
+
+	case "<", "<=", ">", ">=":
+		matched, err = c.processQueryOperationCompare(q, label, lValue, string(op.Type), op.Right)
+
+	case "IN":
+		matched, err = c.processQueryOperationIn(q, lValue, op.Right)
+
+	case "IS NULL":
+		matched = found && values.IsNil(lValue)
+
+	case "IS NOT NULL":
+		matched = found && !values.IsNil(lValue)
+
 	default:
 		err = fmt.Errorf(`%v not implemented`, op.Type)
 
@@ -88,32 +296,231 @@ func (c Context) processQueryOperation(op *cql.Operation) (matched bool, err err
 	return
 }
 
-func (c Context) processQueryOperationEquals(key string, opValue *cql.Value) (matched bool, err error) {
+// processQueryOperationEquals checks the equality of the left value and the
+// operand, fold is true for the case-insensitive ==* and !=* operators which
+// compare strings using Unicode case folding
+func (c Context) processQueryOperationEquals(q *Query, label string, lValue interface{}, opValue *cql.Value, fold bool) (matched bool, err error) {
 	switch {
 
-	case opValue.ContextKey != nil:
-		lValue := c.Get(key)
-		rValue := c.Get(*opValue.ContextKey)
-		matched, err = values.Compare(lValue, rValue)
+	case opValue.ContextKey != nil, opValue.Call != nil, opValue.Arithmetic != nil,
+		opValue.Time != nil, opValue.Duration != nil, opValue.Self != nil:
+		var rValue interface{}
+		if rValue, _, err = c.queryResolve(q, opValue); err != nil {
+			return
+		}
+		if ls, ok := lValue.(string); ok && fold {
+			if rs, ok := rValue.(string); ok {
+				matched = strings.EqualFold(ls, rs)
+				return
+			}
+		}
+		matched, err = queryValuesEqual(lValue, rValue)
 
 	case opValue.Regexp != nil:
-		if value, ok := c.Get(key).(string); ok {
-			if rx, e := _rxc.Compile(*opValue.Regexp); e != nil {
-				err = fmt.Errorf("error compiling regular expression")
-			} else {
+		// equality with a regular expression requires the entire value to
+		// match the pattern, use =~ to match anywhere within the value
+		if value, ok := lValue.(string); ok {
+			pattern := `^(?:` + *opValue.Regexp + `)$`
+			if fold {
+				pattern = `(?i)` + pattern
+			}
+			var rx *regexp.Regexp
+			if rx, err = compileQueryRegexp(pattern); err == nil {
 				matched = rx.MatchString(value)
 			}
 		} else {
-			err = fmt.Errorf("page.%v is of type %T, expected string", key, c.Get(key))
+			err = fmt.Errorf("%v is of type %T, expected string", label, lValue)
 		}
 
 	case opValue.String != nil:
-		if value, ok := c.Get(key).(string); ok {
+		if value, ok := lValue.(string); ok && fold {
+			matched = strings.EqualFold(value, *opValue.String)
+		} else if ok {
 			matched = value == *opValue.String
 		} else {
-			err = fmt.Errorf("page.%v is of type %T, expected string", key, c.Get(key))
+			err = fmt.Errorf("%v is of type %T, expected string", label, lValue)
 		}
 
+	case opValue.Int != nil:
+		matched, err = processQueryOperationEqualsNumber(label, lValue, float64(*opValue.Int), int64(*opValue.Int), true)
+
+	case opValue.Float != nil:
+		matched, err = processQueryOperationEqualsNumber(label, lValue, *opValue.Float, int64(*opValue.Float), false)
+
+	case opValue.Bool != nil:
+		if value, ok := lValue.(bool); ok {
+			matched = value == bool(*opValue.Bool)
+		} else {
+			err = fmt.Errorf("%v is of type %T, expected bool", label, lValue)
+		}
+
+	case opValue.Nil != nil:
+		// missing keys are considered nil
+		matched = values.IsNil(lValue)
+
+	}
+	return
+}
+
+func processQueryOperationEqualsNumber(label string, lValue interface{}, f float64, i int64, isInt bool) (matched bool, err error) {
+	if vi, vf, vIsInt, ok := queryNumber(lValue); !ok {
+		err = fmt.Errorf("%v is of type %T, expected number", label, lValue)
+	} else if isInt && vIsInt {
+		matched = vi == i
+	} else {
+		matched = vf == f
+	}
+	return
+}
+
+func (c Context) processQueryOperationCompare(q *Query, label string, lValue interface{}, opType string, opValue *cql.Value) (matched bool, err error) {
+	switch {
+	case opValue.List != nil, opValue.Regexp != nil, opValue.Bool != nil, opValue.Nil != nil:
+		err = fmt.Errorf("%v expects a number, string, time, duration, context key or function call", opType)
+		return
+	}
+	var rValue interface{}
+	if rValue, _, err = c.queryResolve(q, opValue); err != nil {
+		return
+	}
+
+	var order int
+	if order, err = queryValuesOrder(lValue, rValue); err != nil {
+		err = fmt.Errorf("%v %v: %w", label, opType, err)
+		return
+	}
+
+	switch opType {
+	case "<":
+		matched = order < 0
+	case "<=":
+		matched = order <= 0
+	case ">":
+		matched = order > 0
+	case ">=":
+		matched = order >= 0
+	}
+	return
+}
+
+func (c Context) processQueryOperationIn(q *Query, lValue interface{}, opValue *cql.Value) (matched bool, err error) {
+	var items []*cql.Value
+	if opValue.List != nil {
+		items = opValue.List
+	} else {
+		items = []*cql.Value{opValue}
+	}
+
+	var list []interface{}
+	var patterns []*regexp.Regexp
+	for _, item := range items {
+		switch {
+		case item.ContextKey != nil, item.Call != nil, item.Arithmetic != nil,
+			item.Time != nil, item.Duration != nil, item.Self != nil:
+			var value interface{}
+			if value, _, err = c.queryResolve(q, item); err != nil {
+				return
+			}
+			if elements, ok := querySlice(value); ok {
+				list = append(list, elements...)
+			} else {
+				list = append(list, value)
+			}
+		case item.Regexp != nil:
+			var rx *regexp.Regexp
+			if rx, err = compileQueryRegexp(`^(?:` + *item.Regexp + `)$`); err != nil {
+				return
+			}
+			patterns = append(patterns, rx)
+		case item.String != nil:
+			list = append(list, *item.String)
+		case item.Int != nil:
+			list = append(list, *item.Int)
+		case item.Float != nil:
+			list = append(list, *item.Float)
+		case item.Bool != nil:
+			list = append(list, bool(*item.Bool))
+		case item.Nil != nil:
+			list = append(list, nil)
+		}
+	}
+
+	contains := func(value interface{}) bool {
+		if s, ok := value.(string); ok {
+			for _, rx := range patterns {
+				if rx.MatchString(s) {
+					return true
+				}
+			}
+		}
+		for _, other := range list {
+			if same, e := queryValuesEqual(value, other); e == nil && same {
+				return true
+			}
+		}
+		return false
+	}
+
+	if elements, ok := querySlice(lValue); ok {
+		for _, v := range elements {
+			if matched = contains(v); matched {
+				return
+			}
+		}
+		return
+	}
+	matched = contains(lValue)
+	return
+}
+
+func (c Context) processQueryOperationMatches(q *Query, label string, lValue interface{}, opValue *cql.Value) (matched bool, err error) {
+	var pattern string
+	switch {
+
+	case opValue.Regexp != nil:
+		pattern = *opValue.Regexp
+
+	case opValue.String != nil:
+		pattern = *opValue.String
+
+	case opValue.ContextKey != nil, opValue.Call != nil, opValue.Arithmetic != nil, opValue.Self != nil:
+		var value interface{}
+		if value, _, err = c.queryResolve(q, opValue); err != nil {
+			return
+		}
+		var ok bool
+		if pattern, ok = value.(string); !ok {
+			err = fmt.Errorf("%v is of type %T, expected string", queryLabel(opValue), value)
+			return
+		}
+		// patterns read from the context are not known until evaluation
+		if err = q.stmnt.Limits().CheckRegexp(pattern); err != nil {
+			err = fmt.Errorf("%v: %w", queryLabel(opValue), err)
+			return
+		}
+
+	default:
+		err = fmt.Errorf("=~ and !~ expect a regular expression, string, context key or function call")
+		return
+
+	}
+
+	value, ok := lValue.(string)
+	if !ok {
+		err = fmt.Errorf("%v is of type %T, expected string", label, lValue)
+		return
+	}
+
+	var rx *regexp.Regexp
+	if rx, err = compileQueryRegexp(pattern); err == nil {
+		matched = rx.MatchString(value)
+	}
+	return
+}
+
+func compileQueryRegexp(pattern string) (rx *regexp.Regexp, err error) {
+	if rx, err = _rxc.Compile(pattern); err != nil {
+		err = fmt.Errorf("error compiling regular expression: %w", err)
 	}
 	return
 }
@@ -0,0 +1,785 @@
+// Copyright (c) 2024  The Go-CoreLibs Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package context
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/go-corelibs/context/cql"
+)
+
+func TestMatchQL(t *testing.T) {
+	Convey("Regexp Operators", t, func() {
+		ctx := Context{
+			"Title":   "Draft: the thing",
+			"Pattern": "^Draft",
+			"Count":   10,
+		}
+
+		matched, err := ctx.MatchQL(`(.Title =~ m/^Draft/)`)
+		So(err, ShouldBeNil)
+		So(matched, ShouldBeTrue)
+
+		matched, err = ctx.MatchQL(`(.Title =~ m!thing$!)`)
+		So(err, ShouldBeNil)
+		So(matched, ShouldBeTrue)
+
+		matched, err = ctx.MatchQL(`(.Title !~ m/^Draft/)`)
+		So(err, ShouldBeNil)
+		So(matched, ShouldBeFalse)
+
+		matched, err = ctx.MatchQL(`(.Title =~ .Pattern)`)
+		So(err, ShouldBeNil)
+		So(matched, ShouldBeTrue)
+
+		matched, err = ctx.MatchQL(`(.Title =~ 'the')`)
+		So(err, ShouldBeNil)
+		So(matched, ShouldBeTrue)
+
+		matched, err = ctx.MatchQL(`((.Title !~ m/nope/) AND (.Title != 'nope'))`)
+		So(err, ShouldBeNil)
+		So(matched, ShouldBeTrue)
+
+		// == requires the whole value to match
+		matched, err = ctx.MatchQL(`(.Title == m/^Draft/)`)
+		So(err, ShouldBeNil)
+		So(matched, ShouldBeFalse)
+		matched, err = ctx.MatchQL(`(.Title == m/^Draft.+/)`)
+		So(err, ShouldBeNil)
+		So(matched, ShouldBeTrue)
+
+		matched, err = ctx.MatchQL(`(.Count =~ m/10/)`)
+		So(err, ShouldNotBeNil)
+		So(matched, ShouldBeFalse)
+
+		matched, err = ctx.MatchQL(`(.Title =~ m/(/)`)
+		So(err, ShouldNotBeNil)
+		So(matched, ShouldBeFalse)
+
+		matched, err = ctx.MatchQL(`(.Title =~ nil)`)
+		So(err, ShouldNotBeNil)
+		So(matched, ShouldBeFalse)
+	})
+	Convey("Literal Values", t, func() {
+		ctx := Context{
+			"Int":     3,
+			"Int64":   int64(3),
+			"Float":   3.0,
+			"Half":    1.5,
+			"Draft":   true,
+			"Nothing": nil,
+			"Title":   "three",
+		}
+
+		for _, test := range []struct {
+			query   string
+			matched bool
+			err     bool
+		}{
+			{`(.Int == 3)`, true, false},
+			{`(.Int64 == 3)`, true, false},
+			{`(.Float == 3)`, true, false},
+			{`(.Int != 4)`, true, false},
+			{`(.Half == 1.5)`, true, false},
+			{`(.Int == 3.0)`, true, false},
+			{`(.Int == .Int64)`, true, false},
+			{`(.Int64 == .Float)`, true, false},
+			{`(.Title == 3)`, false, true},
+			{`(.Draft == true)`, true, false},
+			{`(.Draft == false)`, false, false},
+			{`(.Draft != false)`, true, false},
+			{`(.Title == true)`, false, true},
+			{`(.Nothing == nil)`, true, false},
+			{`(.Missing == nil)`, true, false},
+			{`(.Title == nil)`, false, false},
+			{`(.Title != nil)`, true, false},
+			{`(.Missing == .Nothing)`, true, false},
+			{`(.Title == .Nothing)`, false, false},
+		} {
+			matched, err := ctx.MatchQL(test.query)
+			So(err != nil, ShouldEqual, test.err)
+			So(matched, ShouldEqual, test.matched)
+		}
+	})
+	Convey("Ordering Operators", t, func() {
+		now := time.Now()
+		ctx := Context{
+			"Weight":    10,
+			"Price":     int64(25),
+			"Ratio":     0.5,
+			"Huge":      uint64(math.MaxUint64),
+			"Small":     uint64(1),
+			"Name":      "page10",
+			"Other":     "page9",
+			"Published": now.Add(-time.Hour),
+			"Updated":   now,
+			"Draft":     true,
+		}
+
+		for _, test := range []struct {
+			query   string
+			matched bool
+			err     bool
+		}{
+			{`(.Weight >= 10)`, true, false},
+			{`(.Weight > 10)`, false, false},
+			{`(.Weight < 10.5)`, true, false},
+			{`(.Weight <= .Price)`, true, false},
+			{`(.Ratio < 1)`, true, false},
+			{`(.Ratio > .Weight)`, false, false},
+			{`(.Name > .Other)`, true, false},
+			{`(.Name < 'page11')`, true, false},
+			{`(.Name <= 'page10')`, true, false},
+			{`(.Published < .Updated)`, true, false},
+			{`(.Published >= .Updated)`, false, false},
+			{`(.Huge > 0)`, true, false},
+			{`(.Huge > .Price)`, true, false},
+			{`(.Huge < -1)`, false, false},
+			{`(.Huge > .Small)`, true, false},
+			{`(.Small < .Huge)`, true, false},
+			{`(.Small == 1)`, true, false},
+			{`(.Huge == -1)`, false, false},
+			{`(.Name < 10)`, false, true},
+			{`(.Draft < true)`, false, true},
+			{`(.Missing < 10)`, false, true},
+			{`(.Published < .Weight)`, false, true},
+		} {
+			matched, err := ctx.MatchQL(test.query)
+			So(err != nil, ShouldEqual, test.err)
+			So(matched, ShouldEqual, test.matched)
+		}
+	})
+	Convey("Null Predicates", t, func() {
+		ctx := Context{
+			"Summary": nil,
+			"Title":   "title",
+		}
+
+		for _, test := range []struct {
+			query   string
+			matched bool
+		}{
+			{`(.Summary IS NULL)`, true},
+			{`(.Summary IS NOT NULL)`, false},
+			{`(.Title IS NULL)`, false},
+			{`(.Title is not null)`, true},
+			{`(.Missing IS NULL)`, false},
+			{`(.Missing IS NOT NULL)`, false},
+			{`(.Missing == nil)`, true},
+		} {
+			matched, err := ctx.MatchQL(test.query)
+			So(err, ShouldBeNil)
+			So(matched, ShouldEqual, test.matched)
+		}
+	})
+	Convey("IN Operator", t, func() {
+		ctx := Context{
+			"Type":         "post",
+			"DefaultType":  "article",
+			"Count":        int64(3),
+			"Tags":         []interface{}{"go", "cql"},
+			"Labels":       []string{"one", "two"},
+			"AllowedTypes": []string{"page", "post"},
+		}
+
+		for _, test := range []struct {
+			query   string
+			matched bool
+			err     bool
+		}{
+			{`(.Type IN ('page', 'post', .DefaultType))`, true, false},
+			{`(.DefaultType IN ('page', 'post', .DefaultType))`, true, false},
+			{`(.Type IN ('page'))`, false, false},
+			{`(.Type IN .AllowedTypes)`, true, false},
+			{`(.Type IN (.AllowedTypes, 'other'))`, true, false},
+			{`(.Type IN (m/^p.+t$/))`, true, false},
+			{`(.Type IN (m/^p/))`, false, false},
+			{`(.Count IN (1, 2, 3))`, true, false},
+			{`(.Count IN (1.5, 'three'))`, false, false},
+			{`(.Tags IN ('go'))`, true, false},
+			{`(.Tags IN ('rust', 'zig'))`, false, false},
+			{`(.Labels IN ('two'))`, true, false},
+			{`(.Missing IN (nil))`, true, false},
+			{`(.Type IN 'post')`, false, true},
+			{`(.Type == ('post'))`, false, true},
+		} {
+			matched, err := ctx.MatchQL(test.query)
+			So(err != nil, ShouldEqual, test.err)
+			So(matched, ShouldEqual, test.matched)
+		}
+
+		found := Contexts{ctx, {"Type": "page"}, {"Type": "other"}}.FindQL(`(.Type IN ('page', 'post'))`)
+		So(found, ShouldHaveLength, 2)
+	})
+	Convey("Boolean Precedence", t, func() {
+		ctx := Context{
+			"A": 1,
+			"B": 2,
+			"C": 3,
+		}
+
+		for _, test := range []struct {
+			query   string
+			matched bool
+		}{
+			{`.A == 1 AND .B == 2 AND .C == 3`, true},
+			{`.A == 1 AND .B == 2 AND .C == 4`, false},
+			{`.A == 0 AND .B == 0 OR .C == 3`, true},
+			{`.C == 3 OR .A == 0 AND .B == 0`, true},
+			{`(.C == 3 OR .A == 0) AND .B == 0`, false},
+			{`NOT .A == 1`, false},
+			{`NOT (.A == 1 AND .B == 0)`, true},
+			{`NOT .A == 0 AND NOT .B == 0`, true},
+			{`((.A == 1) AND (.B == 2))`, true},
+		} {
+			matched, err := ctx.MatchQL(test.query)
+			So(err, ShouldBeNil)
+			So(matched, ShouldEqual, test.matched)
+		}
+	})
+	Convey("Deep Keys", t, func() {
+		ctx := Context{
+			"Author": map[string]interface{}{"Name": "Ann", "Bio": nil},
+			"Items": Contexts{
+				{"Title": "first", "Weight": 1},
+				{"Title": "second", "Weight": 2},
+			},
+			"Tags": []interface{}{"go", "cql"},
+		}
+
+		for _, test := range []struct {
+			query   string
+			matched bool
+		}{
+			{`.Author.Name == 'Ann'`, true},
+			{`.Items[0].Title == 'first'`, true},
+			{`.Items[1].Weight > .Items[0].Weight`, true},
+			{`.Tags[1] == 'cql'`, true},
+			{`.Author.Bio IS NULL`, true},
+			{`.Author.Missing IS NULL`, false},
+			{`.Items[5].Title == nil`, true},
+			{`.Items[0].Title IN (.Items[1].Title, 'first')`, true},
+		} {
+			matched, err := ctx.MatchQL(test.query)
+			So(err, ShouldBeNil)
+			So(matched, ShouldEqual, test.matched)
+		}
+	})
+	Convey("Short Circuit", t, func() {
+		ctx := Context{
+			"A": "x",
+			"B": 10,
+		}
+
+		for _, test := range []struct {
+			query   string
+			matched bool
+			err     bool
+		}{
+			{`.A == 'x' OR .B =~ m/1/`, true, false},
+			{`.A == 'y' OR .B =~ m/1/`, false, true},
+			{`.A == 'y' AND .B =~ m/1/`, false, false},
+			{`.A == 'x' AND .B =~ m/1/`, false, true},
+			{`.B =~ m/1/ OR .A == 'x'`, false, true},
+			{`NOT (.A == 'y' AND .B =~ m/1/)`, true, false},
+		} {
+			matched, err := ctx.MatchQL(test.query)
+			So(err != nil, ShouldEqual, test.err)
+			So(matched, ShouldEqual, test.matched)
+		}
+	})
+	Convey("Escapes and Flags", t, func() {
+		ctx := Context{
+			"Title": `it's "quoted"`,
+			"Name":  "Über",
+			"Path":  "/blog/post",
+			"Body":  "first\nsecond",
+		}
+
+		for _, test := range []struct {
+			query   string
+			matched bool
+		}{
+			{`.Title == 'it\'s "quoted"'`, true},
+			{`.Title == "it's \"quoted\""`, true},
+			{`.Name == '\u00dcber'`, true},
+			{`.Path =~ m/^\/blog\//`, true},
+			{`.Path == m~/blog/.+~`, true},
+			{`.Name =~ m/^über$/`, false},
+			{`.Name =~ m/^über$/i`, true},
+			{`.Body =~ m/first.second/`, false},
+			{`.Body =~ m/first.second/s`, true},
+			{`.Body =~ m/^second$/`, false},
+			{`.Body =~ m/^second$/m`, true},
+			{`.Name IN (m/über/i)`, true},
+		} {
+			matched, err := ctx.MatchQL(test.query)
+			So(err, ShouldBeNil)
+			So(matched, ShouldEqual, test.matched)
+		}
+	})
+
+	Convey("Numeric Literals and Keys", t, func() {
+		ctx := Context{
+			"Offset":       -5,
+			"Ratio":        0.25,
+			"Big":          1500.0,
+			"Größe":        3,
+			"publish-date": "2024-01-02",
+			"Meta":         map[string]interface{}{"edit date": "2024-02-03"},
+		}
+
+		for _, test := range []struct {
+			query   string
+			matched bool
+		}{
+			{`.Offset == -5`, true},
+			{`.Offset < -4.5`, true},
+			{`.Offset > +1`, false},
+			{`.Ratio == .25`, true},
+			{`.Ratio == 25e-2`, true},
+			{`.Big == 1.5e3`, true},
+			{`.Big >= 15E2 AND .Big < 1e4`, true},
+			{`.Größe == 3`, true},
+			{`."publish-date" == '2024-01-02'`, true},
+			{`.Meta."edit date" > ."publish-date"`, true},
+			{`."missing-key" == nil`, true},
+		} {
+			matched, err := ctx.MatchQL(test.query)
+			So(err, ShouldBeNil)
+			So(matched, ShouldEqual, test.matched)
+		}
+	})
+
+	Convey("Case-Insensitive Equality", t, func() {
+		ctx := Context{
+			"Category": "News",
+			"Name":     "ÜBER straße",
+			"Other":    "news",
+			"Greek":    "ΣΊΣΥΦΟΣ",
+			"Count":    3,
+		}
+
+		for _, test := range []struct {
+			query   string
+			matched bool
+		}{
+			{`.Category == 'news'`, false},
+			{`.Category ==* 'news'`, true},
+			{`.Category ==* 'NEWS'`, true},
+			{`.Category !=* 'news'`, false},
+			{`.Category !=* 'sport'`, true},
+			{`.Category ==* .Other`, true},
+			{`.Category == .Other`, false},
+			{`.Name ==* 'über STRASSE'`, false},
+			{`.Name ==* 'über STRAßE'`, true},
+			{`.Greek ==* 'σίσυφος'`, true},
+			{`.Category ==* m/NEW./`, true},
+			{`.Category == m/NEW./`, false},
+			{`.Count ==* 3`, true},
+			{`.Missing ==* nil`, true},
+		} {
+			matched, err := ctx.MatchQL(test.query)
+			So(err, ShouldBeNil)
+			So(matched, ShouldEqual, test.matched)
+		}
+
+		list := Contexts{{"Category": "News"}, {"Category": "news"}, {"Category": "Sport"}}
+		So(list.FindQL(`.Category ==* 'NEWS'`), ShouldHaveLength, 2)
+		_, err := ctx.MatchQL(`.Category ==* 1`)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Built-in Functions", t, func() {
+		ctx := Context{
+			"Title":    "Going Further",
+			"Slug":     "going-further-with-go",
+			"Category": "News",
+			"Tags":     []interface{}{"go", "cql", "news"},
+			"Meta":     Context{"one": 1, "two": 2},
+			"Weight":   5,
+		}
+
+		for _, test := range []struct {
+			query   string
+			matched bool
+		}{
+			{`contains(.Slug, 'with')`, true},
+			{`contains(.Slug, 'rust')`, false},
+			{`contains(.Tags, 'cql')`, true},
+			{`NOT contains(.Tags, 'rust')`, true},
+			{`startsWith(.Title, 'Go')`, true},
+			{`endsWith(.Title, 'Go')`, false},
+			{`endsWith(.Slug, '-go') AND startsWith(.Slug, 'going')`, true},
+			{`len(.Tags) > 2`, true},
+			{`len(.Tags) == 3`, true},
+			{`len(.Title) == 13`, true},
+			{`len(.Meta) < 3`, true},
+			{`lower(.Category) == 'news'`, true},
+			{`upper(.Category) == 'NEWS'`, true},
+			{`lower(.Category) IN .Tags`, true},
+			{`.Title =~ lower(.Category)`, false},
+			{`startsWith(lower(.Title), 'going')`, true},
+			{`contains(.Slug, lower(.Category))`, false},
+		} {
+			matched, err := ctx.MatchQL(test.query)
+			So(err, ShouldBeNil)
+			So(matched, ShouldEqual, test.matched)
+		}
+
+		for _, test := range []struct {
+			query string
+			err   string
+		}{
+			{`contains(.Weight, 'x')`, "page.Weight is of type int, expected string or list"},
+			{`startsWith(.Title, .Weight)`, "page.Weight is of type int, expected string"},
+			{`lower(.Missing) == 'x'`, "page.Missing is of type <nil>, expected string"},
+			{`len(.Weight) > 1`, "page.Weight is of type int, expected string, list or map"},
+			{`len(lower(.Weight)) > 1`, "page.Weight is of type int, expected string"},
+			{`lower(.Title) > 1`, "lower(page.Title) >: cannot order string with int"},
+		} {
+			_, err := ctx.MatchQL(test.query)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, test.err)
+		}
+
+		_, err := ctx.MatchQL(`lower(.Title)`)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, `lower returns string, expected bool`)
+		_, err = ctx.MatchQL(`missing(.Title)`)
+		So(err, ShouldNotBeNil)
+		_, err = ctx.MatchQL(`len(.Title, .Slug) > 1`)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Registered Functions", t, func() {
+		defer UnregisterQueryFunc("testVisibleIn")
+		defer UnregisterQueryFunc("testScore")
+		defer UnregisterQueryFunc("testBroken")
+		So(RegisterQueryFunc("testVisibleIn", cql.Signature{
+			Args:   []cql.Type{cql.StringType},
+			Result: cql.BoolType,
+		}, func(ctx Context, args ...interface{}) (interface{}, error) {
+			return ctx.String("Locale") == args[0].(string) && !ctx.Bool("Draft"), nil
+		}), ShouldBeNil)
+		So(RegisterQueryFunc("testScore", cql.Signature{
+			Args:   []cql.Type{cql.NumberType, cql.NumberType},
+			Result: cql.NumberType,
+		}, func(ctx Context, args ...interface{}) (interface{}, error) {
+			if _, f, _, _ := queryNumber(args[1]); f == 0 {
+				return nil, errors.New("division by zero")
+			}
+			_, a, _, _ := queryNumber(args[0])
+			_, b, _, _ := queryNumber(args[1])
+			return a / b, nil
+		}), ShouldBeNil)
+		So(RegisterQueryFunc("testBroken", cql.Signature{
+			Args:   []cql.Type{cql.AnyType},
+			Result: cql.StringType,
+		}, func(ctx Context, args ...interface{}) (interface{}, error) {
+			return 1, nil
+		}), ShouldBeNil)
+
+		ctx := Context{"Locale": "en", "Weight": 10, "Draft": false, "Title": "Go"}
+		for _, test := range []struct {
+			query   string
+			matched bool
+		}{
+			{`testVisibleIn('en')`, true},
+			{`testVisibleIn('fr')`, false},
+			{`testVisibleIn(.Locale) AND testScore(.Weight, 4) > 2`, true},
+			{`testScore(.Weight, 4) == 2.5`, true},
+			{`testVisibleIn(lower(.Title))`, false},
+		} {
+			matched, err := ctx.MatchQL(test.query)
+			So(err, ShouldBeNil)
+			So(matched, ShouldEqual, test.matched)
+		}
+
+		list := Contexts{ctx, {"Locale": "en", "Draft": true}, {"Locale": "fr"}}
+		So(list.FindQL(`testVisibleIn('en')`), ShouldHaveLength, 1)
+
+		for _, test := range []struct {
+			query string
+			err   string
+		}{
+			{`testVisibleIn(.Weight)`, "page.Weight is of type int, expected string"},
+			{`testScore(.Weight, .Missing) > 1`, "page.Missing is of type <nil>, expected number"},
+			{`testScore(.Weight, 0) > 1`, "testScore: division by zero"},
+			{`testBroken(.Title) == 'x'`, "testBroken(page.Title) returned int, expected string"},
+		} {
+			_, err := ctx.MatchQL(test.query)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, test.err)
+		}
+
+		_, err := CompileQL(`testVisibleIn(1)`)
+		So(err, ShouldNotBeNil)
+		So(RegisterQueryFunc("testVisibleIn", cql.Signature{Result: cql.BoolType}, func(ctx Context, args ...interface{}) (interface{}, error) {
+			return true, nil
+		}), ShouldNotBeNil)
+		So(RegisterQueryFunc("testMissing", cql.Signature{Result: cql.BoolType}, nil), ShouldNotBeNil)
+		_, found := cql.LookupFunc("testMissing")
+		So(found, ShouldBeFalse)
+
+		q := MustCompileQL(`testBroken(.Title) == 'x'`)
+		So(UnregisterQueryFunc("testBroken"), ShouldBeTrue)
+		So(UnregisterQueryFunc("testBroken"), ShouldBeFalse)
+		_, found = cql.LookupFunc("testBroken")
+		So(found, ShouldBeFalse)
+		_, err = q.Match(ctx)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, `unknown function "testBroken"`)
+	})
+
+	Convey("Times and Durations", t, func() {
+		clock := func() time.Time {
+			return time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		}
+		ctx := Context{
+			"Published": time.Date(2024, 2, 15, 9, 30, 0, 0, time.UTC),
+			"Updated":   time.Date(2024, 2, 16, 21, 30, 0, 0, time.UTC),
+			"Timeout":   90 * time.Minute,
+			"Title":     "Going Further",
+		}
+
+		for _, test := range []struct {
+			query   string
+			matched bool
+		}{
+			{`.Published > now() - 30d`, true},
+			{`.Published > now() - 7d`, false},
+			{`now() - 7d > .Published`, true},
+			{`.Published < 2024-02-16`, true},
+			{`.Published >= 2024-02-15T10:30:00+01:00`, true},
+			{`.Published == 2024-02-15T10:30:00+01:00`, true},
+			{`.Published == 2024-02-15`, false},
+			{`.Updated - .Published >= 1d12h`, true},
+			{`.Updated - .Published == 36h`, true},
+			{`.Updated > .Published + 1d`, true},
+			{`.Timeout == 1h30m`, true},
+			{`.Timeout < 2h - 15m`, true},
+			{`.Timeout IN (30m, 90m)`, true},
+			{`.Published IN (2024-02-15T09:30:00Z, 2024-01-01)`, true},
+			{`.Missing == nil AND .Published != 2024-01-01`, true},
+		} {
+			q, err := CompileQL(test.query)
+			So(err, ShouldBeNil)
+			matched, err := q.WithClock(clock).Match(ctx)
+			So(err, ShouldBeNil)
+			So(matched, ShouldEqual, test.matched)
+		}
+
+		for _, test := range []struct {
+			query string
+			err   string
+		}{
+			{`.Title > now() - 1d`, "page.Title >: cannot order string with time.Time"},
+			{`.Published > .Title - 1d`, "page.Title - 1d: cannot apply - to string and time.Duration"},
+			{`.Published > .Timeout - now()`, "page.Timeout - now(): cannot apply - to time.Duration and time.Time"},
+		} {
+			_, err := MustCompileQL(test.query).WithClock(clock).Match(ctx)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, test.err)
+		}
+
+		// the default clock is time.Now
+		matched, err := Context{"Published": time.Now().Add(-time.Hour)}.MatchQL(`.Published > now() - 1d`)
+		So(err, ShouldBeNil)
+		So(matched, ShouldBeTrue)
+
+		// the clock is retained when binding
+		q, err := MustCompileQL(`.Published > now() - $1`).WithClock(clock).Bind(30 * 24 * time.Hour)
+		So(err, ShouldBeNil)
+		matched, err = q.Match(ctx)
+		So(err, ShouldBeNil)
+		So(matched, ShouldBeTrue)
+	})
+}
+
+func TestMatchQLQuantifiers(t *testing.T) {
+	Convey("Quantifiers", t, func() {
+		ctx := Context{
+			"Authors": Contexts{
+				{"Name": "Ann", "Posts": 3},
+				{"Name": "Bob", "Posts": 0},
+			},
+			"Editors": []Context{
+				{"Name": "Cat", "Roles": []interface{}{"copy", "layout"}},
+			},
+			"Sections": []map[string]interface{}{
+				{"Title": "Intro", "Words": 120},
+				{"Title": "Usage", "Words": 800},
+			},
+			"Tags":    []interface{}{"go", "query", "cql"},
+			"Scores":  []int{3, 5, 8},
+			"Labels":  []string{"draft", "news"},
+			"Meta":    map[string]string{"kind": "page"},
+			"Links":   []interface{}{map[string]interface{}{"URL": "https://example.com"}},
+			"Empty":   []interface{}{},
+			"Nothing": nil,
+			"Title":   "Quantified",
+		}
+
+		for _, test := range []struct {
+			query   string
+			matched bool
+		}{
+			{`ANY .Authors (.Name == 'Ann')`, true},
+			{`ANY .Authors (.Name == 'Dan')`, false},
+			{`ALL .Authors (.Posts >= 0)`, true},
+			{`ALL .Authors (.Posts > 0)`, false},
+			{`any .Editors (contains(.Roles, 'layout'))`, true},
+			{`ANY .Sections (.Words > 500 AND startsWith(.Title, 'U'))`, true},
+			{`ALL .Sections (.Words > 500)`, false},
+			{`ALL .Tags (. =~ m/^[a-z]+$/)`, true},
+			{`ANY .Tags (. == 'cql')`, true},
+			{`ANY .Tags (len(.) > 4)`, true},
+			{`ALL .Scores (. > 2)`, true},
+			{`ANY .Scores (. IN (1, 2))`, false},
+			{`ANY .Links (.URL =~ m/^https:/)`, true},
+			{`.Labels[1] == 'news' AND ANY .Labels (. == 'draft')`, true},
+			{`.Scores[2] > .Scores[0] AND .Scores[1] IN .Scores`, true},
+			{`.Meta.kind == 'page' AND len(.Meta.kind) == 4`, true},
+			{`ANY .Editors (ANY .Roles (. == 'copy'))`, true},
+			{`ANY .Authors (.Name == 'Bob' AND .Title == nil)`, true},
+			{`NOT ANY .Authors (.Name == 'Dan') AND .Title == 'Quantified'`, true},
+			{`ANY .Empty (. == 1)`, false},
+			{`ALL .Empty (. == 1)`, true},
+			{`ANY .Nothing (. == 1)`, false},
+			{`ALL .Missing (. == 1)`, true},
+			{`len(.) == 11`, true},
+		} {
+			matched, err := ctx.MatchQL(test.query)
+			So(err, ShouldBeNil)
+			So(matched, ShouldEqual, test.matched)
+		}
+
+		// the evaluation stops at the first item deciding the result
+		matched, err := ctx.MatchQL(`ANY .Tags (. == 'go' OR . > 1)`)
+		So(err, ShouldBeNil)
+		So(matched, ShouldBeTrue)
+
+		for _, test := range []struct {
+			query string
+			err   string
+		}{
+			{`ANY .Title (. == 'Q')`, "page.Title is of type string, expected list"},
+			{`ALL .Tags (. > 1)`, "page.Tags[0]: . >: cannot order string with int"},
+			{`ANY .Authors (ANY .Name (. == 1))`, "page.Authors[0]: page.Name is of type string, expected list"},
+		} {
+			_, err := ctx.MatchQL(test.query)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, test.err)
+		}
+
+		explained, err := ctx.ExplainQL(`ANY .Tags (. == 'query')`)
+		So(err, ShouldBeNil)
+		So(explained.Matched, ShouldBeTrue)
+		So(explained.Pretty(), ShouldStartWith, "ANY .Tags => true\n  .Tags: ")
+	})
+}
+
+func TestMatchQLArithmetic(t *testing.T) {
+	Convey("Arithmetic", t, func() {
+		ctx := Context{
+			"Stock":     10,
+			"Reserved":  int64(4),
+			"Count":     uint8(7),
+			"Price":     12.5,
+			"Budget":    15,
+			"Ratio":     float32(0.5),
+			"First":     "Ann",
+			"Last":      "Smith",
+			"Zero":      0,
+			"Draft":     true,
+			"Items":     []interface{}{2, 4.5, 8},
+			"Published": time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC),
+			"Timeout":   90 * time.Minute,
+			"Cost":      "10",
+			"Discount":  "2.5",
+		}
+
+		for _, test := range []struct {
+			query   string
+			matched bool
+		}{
+			{`(.Stock - .Reserved > 0)`, true},
+			{`.Stock - .Reserved == 6`, true},
+			{`(.Price * 1.2 <= .Budget)`, true},
+			{`.Price * 1.3 <= .Budget`, false},
+			{`.Stock + .Count * 2 == 24`, true},
+			{`.Stock - .Count - 1 == 2`, true},
+			{`.Stock / 4 == 2`, true},
+			{`.Stock / 4.0 == 2.5`, true},
+			{`.Count % 4 == 3`, true},
+			{`.Price % 5 == 2.5`, true},
+			{`.Ratio * .Stock == 5`, true},
+			{`-1 * .Stock < .Zero`, true},
+			{`.First + ' ' + .Last == 'Ann Smith'`, true},
+			{`lower(.First) + .Last == 'annSmith'`, true},
+			{`len(.First) * 2 == 6`, true},
+			{`ALL .Items (. * 2 >= 4)`, true},
+			{`ANY .Items (. % 2 != 0)`, true},
+			{`.Cost * 1.2 == 12`, true},
+			{`.Cost - .Discount == 7.5`, true},
+			{`.Cost / 4 == 2`, true},
+			{`.Cost % 3 == 1`, true},
+			{`.Cost + '5' == '105'`, true},
+		} {
+			matched, err := ctx.MatchQL(test.query)
+			So(err, ShouldBeNil)
+			So(matched, ShouldEqual, test.matched)
+		}
+
+		for _, test := range []struct {
+			query string
+			err   string
+		}{
+			{`.Stock / .Zero > 1`, "page.Stock / page.Zero: division by zero"},
+			{`.Stock % .Zero > 1`, "page.Stock % page.Zero: division by zero"},
+			{`.Price / .Zero > 1`, "page.Price / page.Zero: division by zero"},
+			{`.Stock + .Count / .Zero > 1`, "page.Count / page.Zero: division by zero"},
+			{`.First * 2 == 'x'`, "page.First * 2: cannot apply * to string and int"},
+			{`.First - .Last == 'x'`, "page.First - page.Last: cannot apply - to string and string"},
+			{`.Cost + 1 == 11`, "page.Cost + 1: cannot apply + to string and int"},
+			{`.Cost * 1d == 10`, "page.Cost * 1d: cannot apply * to string and time.Duration"},
+			{`.First + .Stock == 'x'`, "page.First + page.Stock: cannot apply + to string and int"},
+			{`.Draft + 1 == 2`, "page.Draft + 1: cannot apply + to bool and int"},
+			{`.Missing + 1 == 2`, "page.Missing + 1: cannot apply + to <nil> and int"},
+			{`.Stock + 1d > 1`, "page.Stock + 1d: cannot apply + to int and time.Duration"},
+			{`.Published * 1d == 2024-01-09`, "page.Published * 1d: cannot apply * to time.Time and time.Duration"},
+			{`.Published % .Timeout == 2024-01-09`, "page.Published % page.Timeout: cannot apply % to time.Time and time.Duration"},
+			{`.Timeout * 2d == 0s`, "page.Timeout * 2d: cannot apply * to time.Duration and time.Duration"},
+			{`.Timeout / .Timeout == 1`, "page.Timeout / page.Timeout: cannot apply / to time.Duration and time.Duration"},
+			{`ANY .Items (. / 0 > 1)`, "page.Items[0]: . / 0: division by zero"},
+		} {
+			_, err := ctx.MatchQL(test.query)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, test.err)
+		}
+
+		// errors are reported per expression and skipped expressions are not
+		// evaluated
+		matched, err := ctx.MatchQL(`.Stock > 1 OR .Stock / .Zero > 1`)
+		So(err, ShouldBeNil)
+		So(matched, ShouldBeTrue)
+	})
+}
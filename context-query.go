@@ -0,0 +1,236 @@
+// Copyright (c) 2024  The Go-CoreLibs Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package context
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-corelibs/context/cql"
+)
+
+var errUnboundQuery = errors.New("query has unbound placeholders")
+
+// Query is a compiled context query statement, ready to be matched against
+// any number of Context instances without parsing the query again
+//
+// Query instances are immutable and safe for concurrent use
+type Query struct {
+	query   string
+	source  *cql.Statement
+	stmnt   *cql.Statement
+	unbound bool
+	clock   func() time.Time
+	// scoped is true while evaluating an ANY or ALL expression, with self
+	// being the current item
+	scoped bool
+	self   interface{}
+	// resolved records the values of the operands resolved, for Explain
+	resolved map[*cql.Value]interface{}
+}
+
+// CompileQL parses the given context query statement into a new Query,
+// enforcing the cql.DefaultLimits
+func CompileQL(query string) (q *Query, err error) {
+	return CompileQLWithLimits(query, cql.DefaultLimits)
+}
+
+// CompileQLWithLimits is like CompileQL and enforces the given limits, both
+// when compiling and when evaluating the Query. Use this for queries from
+// untrusted sources, such as end users:
+//
+//	q, err := CompileQLWithLimits(userInput, cql.Limits{
+//	    MaxLength:       1024,
+//	    MaxDepth:        8,
+//	    MaxOperations:   32,
+//	    MaxRegexpLength: 256,
+//	})
+//	var limitErr *cql.LimitError
+//	if errors.As(err, &limitErr) {
+//	    // the query is too expensive
+//	}
+func CompileQLWithLimits(query string, limits cql.Limits) (q *Query, err error) {
+	var stmnt *cql.Statement
+	var pErr *cql.ParseError
+	if stmnt, pErr = cql.CompileWithLimits(query, limits); pErr != nil {
+		err = error(pErr)
+		return
+	}
+	q = newQuery(stmnt)
+	return
+}
+
+// CompileQLJSON is like CompileQL for the JSON form of a statement, such as a
+// saved search stored with cql.Statement.Stringify, see cql.CompileJSON
+func CompileQLJSON(data []byte) (q *Query, err error) {
+	var stmnt *cql.Statement
+	if stmnt, err = cql.CompileJSON(data); err == nil {
+		q = newQuery(stmnt)
+	}
+	return
+}
+
+func newQuery(stmnt *cql.Statement) (q *Query) {
+	q = &Query{
+		query:   stmnt.String(),
+		source:  stmnt,
+		stmnt:   stmnt.Render(),
+		unbound: stmnt.HasPlaceholders(),
+	}
+	return
+}
+
+// MustCompileQL is like CompileQL and panics on error
+func MustCompileQL(query string) (q *Query) {
+	var err error
+	if q, err = CompileQL(query); err != nil {
+		panic(err)
+	}
+	return
+}
+
+// String returns the normalized query statement
+func (q *Query) String() string {
+	return q.query
+}
+
+// ContextKeys returns a copy of the list of context keys used by this Query
+func (q *Query) ContextKeys() (keys []string) {
+	keys = append(keys, q.stmnt.ContextKeys...)
+	return
+}
+
+// Match checks if this Query matches the given Context, see Context.MatchQL
+// for the details of the query language
+//
+// Queries with placeholders must be bound before matching, see Bind
+func (q *Query) Match(c Context) (matched bool, err error) {
+	if q.unbound {
+		err = errUnboundQuery
+		return
+	}
+	if matched, err = c.processQueryExpression(q, q.stmnt.Expression); err != nil {
+		matched = false
+	}
+	return
+}
+
+// Suggest returns "did you mean" suggestions for the context keys used by
+// this Query which are not present in the sample Context given, with the
+// candidates drawn from the DeepKeys of the sample
+//
+// Example:
+//
+//	q := MustCompileQL(`.Titel == 'one'`)
+//	for _, suggestion := range q.Suggest(Context{"Title": "one"}) {
+//	    fmt.Println(suggestion) // unknown context key .Titel, did you mean .Title?
+//	}
+func (q *Query) Suggest(sample Context) (suggestions []*cql.Suggestion) {
+	var known []string
+	for _, key := range sample.DeepKeys() {
+		known = append(known, strings.TrimPrefix(key, "."))
+	}
+	suggestions = q.source.Suggest(known, func(key string) (found bool) {
+		_, found = sample.DeepValue(key)
+		return
+	})
+	return
+}
+
+// Bind returns a new Query with the placeholders replaced by the arguments
+// given, see cql.Statement.Bind for the details
+//
+// Example:
+//
+//	q := MustCompileQL(`.Title =~ $1 AND .Type IN :types`)
+//	bound, err := q.Bind(
+//	    regexp.MustCompile(userInput),
+//	    cql.Named("types", []string{"page", "post"}),
+//	)
+func (q *Query) Bind(args ...interface{}) (bound *Query, err error) {
+	var stmnt *cql.Statement
+	if stmnt, err = q.source.Bind(args...); err == nil {
+		bound = newQuery(stmnt)
+		bound.clock = q.clock
+	}
+	return
+}
+
+// Rewrite returns a new Query with the statement rewritten by fn, see
+// cql.Statement.Rewrite for the details
+func (q *Query) Rewrite(fn cql.RewriteFunc) (rewritten *Query, err error) {
+	var stmnt *cql.Statement
+	if stmnt, err = q.source.Rewrite(fn); err == nil {
+		rewritten = newQuery(stmnt)
+		rewritten.clock = q.clock
+	}
+	return
+}
+
+// WithClock returns a copy of this Query which uses the given clock for the
+// current time returned by the now() function, instead of time.Now. This is
+// intended for testing queries such as: .Published > now() - 30d
+func (q *Query) WithClock(clock func() time.Time) (clocked *Query) {
+	copied := *q
+	copied.clock = clock
+	clocked = &copied
+	return
+}
+
+// scope returns a copy of this Query for evaluating an ANY or ALL expression
+// with the given item as the current item
+func (q *Query) scope(item interface{}) (scoped *Query) {
+	copied := *q
+	copied.scoped, copied.self = true, item
+	copied.resolved = nil
+	scoped = &copied
+	return
+}
+
+// tracing returns a copy of this Query which records the values of the
+// operands resolved, so that Explain can report the values actually used
+func (q *Query) tracing() (traced *Query) {
+	copied := *q
+	copied.resolved = make(map[*cql.Value]interface{})
+	traced = &copied
+	return
+}
+
+// now returns the current time, according to the clock of this Query
+func (q *Query) now() time.Time {
+	if q.clock != nil {
+		return q.clock()
+	}
+	return time.Now()
+}
+
+// QueryError is the evaluation error of a specific Context, see
+// Contexts.FindQueryWithErrors
+type QueryError struct {
+	// Index is the position of the Context within the Contexts searched
+	Index int
+	// Err is the evaluation error
+	Err error
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("context %d: %v", e.Index, e.Err)
+}
+
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
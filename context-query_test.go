@@ -0,0 +1,316 @@
+// Copyright (c) 2024  The Go-CoreLibs Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package context
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/go-corelibs/context/cql"
+)
+
+const benchmarkQuery = `.Type IN ('page', 'post') AND .Weight >= 500 AND .Title =~ m/^Page \d+0$/`
+
+func makeBenchmarkContexts(count int) (list Contexts) {
+	for idx := 0; idx < count; idx++ {
+		list = append(list, Context{
+			"Type":   []string{"page", "post", "other"}[idx%3],
+			"Weight": idx,
+			"Title":  fmt.Sprintf("Page %d", idx),
+		})
+	}
+	return
+}
+
+func TestQuery(t *testing.T) {
+	Convey("CompileQL", t, func() {
+		q, err := CompileQL(`.Title == 'one' or .Title == "two"`)
+		So(err, ShouldBeNil)
+		So(q.String(), ShouldEqual, `((.Title == 'one') OR (.Title == "two"))`)
+		So(q.ContextKeys(), ShouldEqual, []string{"Title"})
+
+		matched, err := q.Match(Context{"Title": "two"})
+		So(err, ShouldBeNil)
+		So(matched, ShouldBeTrue)
+		matched, err = q.Match(Context{"Title": "three"})
+		So(err, ShouldBeNil)
+		So(matched, ShouldBeFalse)
+
+		q, err = CompileQL(`.Title ==`)
+		So(err, ShouldNotBeNil)
+		So(q, ShouldBeNil)
+		So(func() { MustCompileQL(`.Title ==`) }, ShouldPanic)
+	})
+
+	Convey("FindQuery", t, func() {
+		list := makeBenchmarkContexts(1000)
+		q := MustCompileQL(benchmarkQuery)
+		found := list.FindQuery(q)
+		So(found, ShouldHaveLength, 33)
+		So(list.FindQL(benchmarkQuery), ShouldEqual, found)
+
+		var wg sync.WaitGroup
+		results := make([]int, 8)
+		for idx := range results {
+			wg.Add(1)
+			go func(idx int) {
+				defer wg.Done()
+				results[idx] = len(list.FindQuery(q))
+			}(idx)
+		}
+		wg.Wait()
+		for _, count := range results {
+			So(count, ShouldEqual, 33)
+		}
+	})
+
+	Convey("CompileQLJSON", t, func() {
+		saved := MustCompileQL(`.Title == 'it\'s' AND .Tags IN ('go', :tag)`)
+		q, err := CompileQLJSON([]byte(saved.stmnt.Stringify()))
+		So(err, ShouldBeNil)
+		So(q.String(), ShouldEqual, `((.Title == "it's") AND (.Tags IN ('go', :tag)))`)
+		So(q.ContextKeys(), ShouldEqual, []string{"Tags", "Title"})
+		q, err = q.Bind(cql.Named("tag", "cql"))
+		So(err, ShouldBeNil)
+		matched, err := q.Match(Context{"Title": "it's", "Tags": []string{"cql"}})
+		So(err, ShouldBeNil)
+		So(matched, ShouldBeTrue)
+
+		_, err = CompileQLJSON([]byte(`{"expressions": {}}`))
+		So(err, ShouldNotBeNil)
+
+		// builder values are usable when rewriting loaded statements
+		q, err = CompileQLJSON([]byte(saved.stmnt.Stringify()))
+		So(err, ShouldBeNil)
+		q, err = q.Rewrite(func(node cql.Node) (cql.Node, error) {
+			if s, ok := node.(*cql.Statement); ok {
+				s.Expression = cql.And(cql.Eq("Tenant", cql.StringValue("acme")), s.Expression)
+			}
+			return node, nil
+		})
+		So(err, ShouldBeNil)
+		So(q.String(), ShouldStartWith, `((.Tenant == 'acme') AND`)
+		q, err = q.Bind(cql.Named("tag", "cql"))
+		So(err, ShouldBeNil)
+		matched, err = q.Match(Context{"Tenant": "acme", "Title": "it's", "Tags": []string{"cql"}})
+		So(err, ShouldBeNil)
+		So(matched, ShouldBeTrue)
+	})
+
+	Convey("Suggest", t, func() {
+		q := MustCompileQL(`.Titel == 'one' AND .Author.nmae == 'Ann' AND .Items[1].Title == nil AND .title == 'one'`)
+		suggestions := q.Suggest(Context{
+			"Title":  "one",
+			"Author": Context{"Name": "Ann"},
+			"Items":  Contexts{{"Title": "first"}},
+		})
+		So(suggestions, ShouldHaveLength, 3)
+		So(suggestions[0].String(), ShouldEqual, `unknown context key .Author.nmae, did you mean .Author.Name?`)
+		So(suggestions[1].String(), ShouldEqual, `unknown context key .Items[1].Title, did you mean .Items[0].Title?`)
+		So(suggestions[2].String(), ShouldEqual, `unknown context key .Titel, did you mean .Title?`)
+	})
+
+	Convey("Rewrite", t, func() {
+		q := MustCompileQL(`.Date > '2024'`)
+		rewritten, err := q.Rewrite(cql.RenameKey("Date", "Published"))
+		So(err, ShouldBeNil)
+		So(rewritten.String(), ShouldEqual, `(.Published > '2024')`)
+		So(rewritten.ContextKeys(), ShouldEqual, []string{"Published"})
+		matched, err := rewritten.Match(Context{"Published": "2025", "Date": "2023"})
+		So(err, ShouldBeNil)
+		So(matched, ShouldBeTrue)
+		So(q.String(), ShouldEqual, `(.Date > '2024')`)
+	})
+}
+
+func TestQueryErrors(t *testing.T) {
+	Convey("FindQueryWithErrors", t, func() {
+		list := Contexts{
+			{"Title": "one"},
+			{"Title": 2},
+			{"Title": "three"},
+			{"Title": true},
+		}
+
+		So(list.FindQL(`.Title =~ m/e$/`), ShouldHaveLength, 2)
+
+		found, err := list.FindQLWithErrors(`.Title =~ m/e$/`)
+		So(found, ShouldEqual, Contexts{list[0], list[2]})
+		So(err, ShouldNotBeNil)
+		var qe *QueryError
+		So(errors.As(err, &qe), ShouldBeTrue)
+		So(qe.Index, ShouldEqual, 1)
+		So(err.Error(), ShouldContainSubstring, "context 3: ")
+
+		found, err = list.FindQLWithErrors(`.Title IS NOT NULL`)
+		So(found, ShouldHaveLength, 4)
+		So(err, ShouldBeNil)
+
+		found, err = list.FindQLWithErrors(`.Title ==`)
+		So(found, ShouldBeNil)
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestQueryBind(t *testing.T) {
+	Convey("Placeholders", t, func() {
+		ctx := Context{"Title": "it's ) OR (.Title != ''", "Type": "post", "Weight": 5}
+
+		matched, err := ctx.MatchQLArgs(`.Title == $1`, ctx["Title"])
+		So(err, ShouldBeNil)
+		So(matched, ShouldBeTrue)
+
+		matched, err = ctx.MatchQLArgs(`.Title == $1`, "nope ') OR (.Title != '")
+		So(err, ShouldBeNil)
+		So(matched, ShouldBeFalse)
+
+		matched, err = ctx.MatchQLArgs(`.Title =~ :rx AND .Type IN :types AND .Weight > $1`,
+			3,
+			cql.Named("rx", regexp.MustCompile(`^it's`)),
+			cql.Named("types", []string{"page", "post"}),
+		)
+		So(err, ShouldBeNil)
+		So(matched, ShouldBeTrue)
+
+		matched, err = ctx.MatchQL(`.Title == $1`)
+		So(err, ShouldNotBeNil)
+		So(matched, ShouldBeFalse)
+
+		explained, err := ctx.ExplainQL(`.Title == $1`)
+		So(err, ShouldNotBeNil)
+		So(explained.Err, ShouldEqual, err)
+
+		matched, err = ctx.MatchQLArgs(`.Title == $1`)
+		So(err, ShouldNotBeNil)
+		So(matched, ShouldBeFalse)
+
+		list := makeBenchmarkContexts(100)
+		So(list.FindQLArgs(`.Weight >= $1 AND .Type == $2`, 90, "page"), ShouldHaveLength, 4)
+		So(list.FindQLArgs(`.Weight >= $1 AND .Type == $2`, 90), ShouldHaveLength, 0)
+
+		q := MustCompileQL(`.Weight == $1`)
+		for _, weight := range []int{1, 2, 3} {
+			bound, err := q.Bind(weight)
+			So(err, ShouldBeNil)
+			So(bound.String(), ShouldEqual, fmt.Sprintf("(.Weight == %d)", weight))
+			So(list.FindQuery(bound), ShouldEqual, Contexts{list[weight]})
+		}
+		So(list.FindQuery(q), ShouldHaveLength, 0)
+
+		huge := Context{"Huge": uint64(math.MaxUint64)}
+		matched, err = huge.MatchQLArgs(`.Huge == $1 AND .Huge > $2`, uint64(math.MaxUint64), uint64(math.MaxInt64))
+		So(err, ShouldBeNil)
+		So(matched, ShouldBeTrue)
+	})
+}
+
+func TestQueryLimits(t *testing.T) {
+	limits := cql.Limits{MaxLength: 64, MaxDepth: 3, MaxOperations: 3, MaxRegexpLength: 8}
+
+	Convey("Compile Limits", t, func() {
+		for _, test := range []struct {
+			query string
+			limit string
+		}{
+			{`.Title == '` + strings.Repeat("x", 64) + `'`, "MaxLength"},
+			{`NOT NOT NOT .A == 1`, "MaxDepth"},
+			{`.A == .B * 2 * 3 + 1`, "MaxDepth"},
+			{`.A == 1 OR .B == 2 OR .C == 3 OR .D == 4`, "MaxOperations"},
+			{`.A =~ m/^[a-z]+[0-9]+$/`, "MaxRegexpLength"},
+			{`.A =~ '^[a-z]+[0-9]+$'`, "MaxRegexpLength"},
+			{`.A IN ('x', m/^[a-z]+[0-9]+$/)`, "MaxRegexpLength"},
+		} {
+			_, err := CompileQLWithLimits(test.query, limits)
+			var limitErr *cql.LimitError
+			So(errors.As(err, &limitErr), ShouldBeTrue)
+			So(limitErr.Limit, ShouldEqual, test.limit)
+		}
+
+		q, err := CompileQLWithLimits(`(.A == 1 OR .B == 2) AND .C =~ m/^x/`, limits)
+		So(err, ShouldBeNil)
+		So(q, ShouldNotBeNil)
+		_, err = CompileQL(`(((.A == 1))) OR .B == 2 OR .C == 3 OR .D == 4`)
+		So(err, ShouldBeNil)
+	})
+
+	Convey("Evaluation Limits", t, func() {
+		q, err := CompileQLWithLimits(`.Title =~ .Pattern`, limits)
+		So(err, ShouldBeNil)
+		matched, err := q.Match(Context{"Title": "abc", "Pattern": "^a"})
+		So(err, ShouldBeNil)
+		So(matched, ShouldBeTrue)
+		matched, err = q.Match(Context{"Title": "abc", "Pattern": "^(a|b|c)+$"})
+		var limitErr *cql.LimitError
+		So(errors.As(err, &limitErr), ShouldBeTrue)
+		So(limitErr.Limit, ShouldEqual, "MaxRegexpLength")
+		So(matched, ShouldBeFalse)
+
+		q, err = CompileQLWithLimits(`.Title =~ $1`, limits)
+		So(err, ShouldBeNil)
+		_, err = q.Bind("^(a|b|c)+$")
+		So(errors.As(err, &limitErr), ShouldBeTrue)
+	})
+
+	Convey("Regexp Cache", t, func() {
+		defer SetRegexpCacheSize(DefaultRegexpCacheSize)
+		_rxc.Reset()
+		SetRegexpCacheSize(2)
+		for _, pattern := range []string{"a", "b", "a", "c"} {
+			_, err := _rxc.Compile(pattern)
+			So(err, ShouldBeNil)
+		}
+		So(_rxc.Len(), ShouldEqual, 2)
+		So(_rxc.data, ShouldContainKey, "a")
+		So(_rxc.data, ShouldContainKey, "c")
+		_, err := _rxc.Compile("(")
+		So(err, ShouldNotBeNil)
+		_, err = _rxc.Compile("(")
+		So(err, ShouldNotBeNil)
+		SetRegexpCacheSize(0)
+		So(_rxc.Len(), ShouldEqual, 0)
+		_, err = _rxc.Compile("a")
+		So(err, ShouldBeNil)
+		So(_rxc.Len(), ShouldEqual, 0)
+	})
+}
+
+func BenchmarkMatchQL(b *testing.B) {
+	list := makeBenchmarkContexts(1000)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		var found Contexts
+		for _, ctx := range list {
+			if matched, _ := ctx.MatchQL(benchmarkQuery); matched {
+				found = append(found, ctx)
+			}
+		}
+	}
+}
+
+func BenchmarkFindQuery(b *testing.B) {
+	list := makeBenchmarkContexts(1000)
+	q := MustCompileQL(benchmarkQuery)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		_ = list.FindQuery(q)
+	}
+}
@@ -15,6 +15,7 @@
 package context
 
 import (
+	"errors"
 	"math"
 
 	"github.com/go-corelibs/maths"
@@ -26,12 +27,61 @@ func (c Contexts) Len() (count int) {
 	return len(c)
 }
 
+// FindQL compiles the given context query statement and returns the Contexts
+// matching it, see FindQuery
 func (c Contexts) FindQL(query string) (found Contexts) {
+	if q, err := CompileQL(query); err == nil {
+		found = c.FindQuery(q)
+	}
+	return
+}
+
+// FindQLArgs is like FindQL with the query placeholders bound to the
+// arguments given, see Query.Bind
+func (c Contexts) FindQLArgs(query string, args ...interface{}) (found Contexts) {
+	if q, err := CompileQL(query); err == nil {
+		if q, err = q.Bind(args...); err == nil {
+			found = c.FindQuery(q)
+		}
+	}
+	return
+}
+
+// FindQuery returns the Contexts matching the given Query, any Context
+// resulting in an evaluation error is skipped, use FindQueryWithErrors to
+// collect the errors instead
+func (c Contexts) FindQuery(q *Query) (found Contexts) {
 	for _, ctx := range c {
-		if matched, _ := ctx.MatchQL(query); matched {
+		if matched, _ := q.Match(ctx); matched {
+			found = append(found, ctx)
+		}
+	}
+	return
+}
+
+// FindQLWithErrors is like FindQL except that the query parse error or any
+// evaluation errors are returned, see FindQueryWithErrors
+func (c Contexts) FindQLWithErrors(query string) (found Contexts, err error) {
+	var q *Query
+	if q, err = CompileQL(query); err == nil {
+		found, err = c.FindQueryWithErrors(q)
+	}
+	return
+}
+
+// FindQueryWithErrors is like FindQuery except that the evaluation errors are
+// collected, each as a *QueryError, and returned together using errors.Join.
+// The Contexts which did match are returned regardless of any errors
+func (c Contexts) FindQueryWithErrors(q *Query) (found Contexts, err error) {
+	var errs []error
+	for idx, ctx := range c {
+		if matched, e := q.Match(ctx); e != nil {
+			errs = append(errs, &QueryError{Index: idx, Err: e})
+		} else if matched {
 			found = append(found, ctx)
 		}
 	}
+	err = errors.Join(errs...)
 	return
 }
 
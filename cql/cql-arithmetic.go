@@ -0,0 +1,138 @@
+// Copyright (c) 2024  The Go-CoreLibs Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cql
+
+import (
+	"fmt"
+)
+
+// Arithmetic applies the Operator to the Left and Right operands, such as the
+// subtraction in: .Published > now() - 30d
+//
+// The operators are +, -, *, / and %, with *, / and % applied before + and -
+// and operators of the same precedence applied from left to right. There is
+// no syntax for grouping and so an operand which is itself an Arithmetic must
+// be one the query syntax would produce: the Left operand cannot have a lower
+// precedence operator and the Right operand must have a higher one
+type Arithmetic struct {
+	Left     *Value `json:"left"`
+	Operator string `json:"operator"`
+	Right    *Value `json:"right"`
+}
+
+// Clone returns a deep copy of this Arithmetic
+func (a *Arithmetic) Clone() (clone *Arithmetic) {
+	clone = &Arithmetic{Operator: a.Operator}
+	if a.Left != nil {
+		clone.Left = a.Left.Clone()
+	}
+	if a.Right != nil {
+		clone.Right = a.Right.Clone()
+	}
+	return
+}
+
+// Render returns a copy of this Arithmetic with the operands rendered
+func (a *Arithmetic) Render() (clone *Arithmetic) {
+	clone = &Arithmetic{Operator: a.Operator}
+	if a.Left != nil {
+		clone.Left = a.Left.Render()
+	}
+	if a.Right != nil {
+		clone.Right = a.Right.Render()
+	}
+	return
+}
+
+// format returns the query syntax for this Arithmetic, see Value.format
+func (a *Arithmetic) format(quoted bool) (text string) {
+	text = a.Left.format(quoted) + " " + a.Operator + " " + a.Right.format(quoted)
+	return
+}
+
+// validate checks the operator and operands of this Arithmetic and that the
+// operand types are compatible, as far as they are known before evaluation
+func (a *Arithmetic) validate(quoted bool) (err error) {
+	precedence := arithmeticPrecedence(a.Operator)
+	if precedence == 0 {
+		err = fmt.Errorf("unknown arithmetic operator %q", a.Operator)
+		return
+	}
+	for _, operand := range []*Value{a.Left, a.Right} {
+		if operand == nil {
+			err = fmt.Errorf("%v: missing operand", a.Operator)
+		} else if operand.List != nil {
+			err = atPosition(operand.Pos, fmt.Errorf("%v does not accept a list", a.Operator))
+		} else if operand.Regexp != nil {
+			err = atPosition(operand.Pos, fmt.Errorf("%v does not accept a regexp", a.Operator))
+		} else if operand.Nil != nil {
+			err = atPosition(operand.Pos, fmt.Errorf("%v does not accept nil", a.Operator))
+		} else {
+			err = operand.validate(quoted)
+		}
+		if err != nil {
+			return
+		}
+	}
+	if a.Left.Arithmetic != nil && arithmeticPrecedence(a.Left.Arithmetic.Operator) < precedence {
+		err = fmt.Errorf("%v: the left operand cannot be a %v expression", a.Operator, a.Left.Arithmetic.Operator)
+		return
+	} else if a.Right.Arithmetic != nil && arithmeticPrecedence(a.Right.Arithmetic.Operator) <= precedence {
+		err = fmt.Errorf("%v: the right operand cannot be a %v expression", a.Operator, a.Right.Arithmetic.Operator)
+		return
+	}
+	_, err = a.resultType()
+	return
+}
+
+// arithmeticPrecedence returns the precedence of the given operator, higher
+// values are applied first and zero is an unknown operator
+func arithmeticPrecedence(operator string) (precedence int) {
+	switch operator {
+	case "+", "-":
+		precedence = 1
+	case "*", "/", "%":
+		precedence = 2
+	}
+	return
+}
+
+// resultType returns the Type of the result of this Arithmetic, which is
+// AnyType when either operand type is not known before evaluation
+func (a *Arithmetic) resultType() (t Type, err error) {
+	lt, rt := valueType(a.Left), valueType(a.Right)
+	additive := a.Operator == "+" || a.Operator == "-"
+	switch {
+	case lt == AnyType || rt == AnyType:
+		t = AnyType
+	case lt == NumberType && rt == NumberType:
+		t = NumberType
+	case lt == StringType && rt == StringType && a.Operator == "+":
+		t = StringType
+	case !additive:
+		err = fmt.Errorf("cannot apply %v to %v and %v", a.Operator, lt, rt)
+	case lt == TimeType && rt == DurationType:
+		t = TimeType
+	case lt == DurationType && rt == TimeType && a.Operator == "+":
+		t = TimeType
+	case lt == DurationType && rt == DurationType:
+		t = DurationType
+	case lt == TimeType && rt == TimeType && a.Operator == "-":
+		t = DurationType
+	default:
+		err = fmt.Errorf("cannot apply %v to %v and %v", a.Operator, lt, rt)
+	}
+	return
+}
@@ -0,0 +1,291 @@
+// Copyright (c) 2024  The Go-CoreLibs Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cql
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// NamedArg is a Bind argument for a :name placeholder
+type NamedArg struct {
+	Name  string
+	Value interface{}
+}
+
+// Named returns a new NamedArg, the name is given without the leading colon
+func Named(name string, value interface{}) NamedArg {
+	return NamedArg{Name: name, Value: value}
+}
+
+// Bind returns a copy of this Statement with all placeholders replaced by the
+// arguments given. Positional placeholders ($1, $2, etc) refer to the
+// arguments by their position (starting with one) and named placeholders
+// (:name) refer to the NamedArg arguments with the same name
+//
+// Arguments are converted directly into Value instances and are never parsed
+// as query syntax, the supported types are: string, *regexp.Regexp, all int,
+// uint and float types, bool, time.Time, time.Duration, nil and slices of
+// these (for use with IN)
+//
+// Bind returns an error if any placeholder has no argument, if the bound
+// Statement is not valid or if it exceeds the Limits of this Statement
+func (s *Statement) Bind(args ...interface{}) (bound *Statement, err error) {
+	named := make(map[string]interface{})
+	for _, arg := range args {
+		if na, ok := arg.(NamedArg); ok {
+			named[na.Name] = na.Value
+		}
+	}
+
+	b := &binder{args: args, named: named, rendered: s.rendered}
+	out := &Statement{rendered: s.rendered, limits: s.limits}
+	out.ContextKeys = append(out.ContextKeys, s.ContextKeys...)
+	if s.Expression != nil {
+		if out.Expression, err = b.expression(s.Expression); err != nil {
+			return
+		}
+	}
+	if pErr := validateExpression(out.Expression, false); pErr != nil {
+		err = errors.New(pErr.Message())
+		return
+	}
+	if pErr := s.limits.checkExpression(out.Expression, !s.rendered); pErr != nil {
+		err = pErr
+		return
+	}
+	bound = out
+	return
+}
+
+// HasPlaceholders returns true if this Statement has any placeholders
+func (s *Statement) HasPlaceholders() (present bool) {
+	var check func(expr *Expression) bool
+	var checkValue func(v *Value) bool
+	checkValue = func(v *Value) bool {
+		if v == nil {
+			return false
+		} else if v.Placeholder != nil {
+			return true
+		}
+		for _, item := range v.List {
+			if checkValue(item) {
+				return true
+			}
+		}
+		if v.Call != nil {
+			for _, arg := range v.Call.Args {
+				if checkValue(arg) {
+					return true
+				}
+			}
+		}
+		if v.Arithmetic != nil {
+			return checkValue(v.Arithmetic.Left) || checkValue(v.Arithmetic.Right)
+		}
+		return false
+	}
+	check = func(expr *Expression) bool {
+		switch {
+		case expr == nil:
+		case expr.Operation != nil:
+			return checkValue(expr.Operation.Left) || checkValue(expr.Operation.Right)
+		case expr.Quantifier != nil:
+			return check(expr.Quantifier.Expression)
+		case expr.Condition != nil:
+			return check(expr.Condition.Left) || check(expr.Condition.Right)
+		}
+		return false
+	}
+	present = check(s.Expression)
+	return
+}
+
+type binder struct {
+	args     []interface{}
+	named    map[string]interface{}
+	rendered bool
+}
+
+func (b *binder) expression(expr *Expression) (bound *Expression, err error) {
+	bound = &Expression{}
+	switch {
+
+	case expr.Operation != nil:
+		op := *expr.Operation
+		if op.Left != nil {
+			if op.Left, err = b.value(op.Left); err != nil {
+				return
+			}
+		}
+		if op.Right != nil {
+			if op.Right, err = b.value(op.Right); err != nil {
+				err = fmt.Errorf("%v: %w", op.Left.format(!b.rendered), err)
+				return
+			}
+		}
+		bound.Operation = &op
+
+	case expr.Quantifier != nil:
+		quant := *expr.Quantifier
+		if quant.Expression != nil {
+			if quant.Expression, err = b.expression(quant.Expression); err != nil {
+				return
+			}
+		}
+		bound.Quantifier = &quant
+
+	case expr.Condition != nil:
+		cond := *expr.Condition
+		if cond.Left != nil {
+			if cond.Left, err = b.expression(cond.Left); err != nil {
+				return
+			}
+		}
+		if cond.Right != nil {
+			if cond.Right, err = b.expression(cond.Right); err != nil {
+				return
+			}
+		}
+		bound.Condition = &cond
+
+	}
+	return
+}
+
+func (b *binder) value(v *Value) (bound *Value, err error) {
+	switch {
+
+	case v.Placeholder != nil:
+		name := *v.Placeholder
+		var arg interface{}
+		var ok bool
+		if name[0] == '$' {
+			if idx, e := strconv.Atoi(name[1:]); e == nil && idx > 0 && idx <= len(b.args) {
+				arg, ok = b.args[idx-1], true
+			}
+		} else {
+			arg, ok = b.named[name[1:]]
+		}
+		if !ok {
+			err = fmt.Errorf("missing argument for placeholder %v", name)
+			return
+		}
+		if na, isNamed := arg.(NamedArg); isNamed && name[0] == '$' {
+			arg = na.Value
+		}
+		bound, err = b.argument(arg)
+
+	case v.List != nil:
+		// placeholders bound to lists are expanded in place
+		bound = &Value{List: make([]*Value, 0, len(v.List))}
+		for _, item := range v.List {
+			var boundItem *Value
+			if boundItem, err = b.value(item); err != nil {
+				return
+			} else if item.Placeholder != nil && boundItem.List != nil {
+				bound.List = append(bound.List, boundItem.List...)
+			} else {
+				bound.List = append(bound.List, boundItem)
+			}
+		}
+
+	case v.Call != nil:
+		bound = &Value{Call: &Call{Name: v.Call.Name}}
+		for _, arg := range v.Call.Args {
+			var boundArg *Value
+			if boundArg, err = b.value(arg); err != nil {
+				return
+			}
+			bound.Call.Args = append(bound.Call.Args, boundArg)
+		}
+
+	case v.Arithmetic != nil:
+		bound = &Value{Arithmetic: &Arithmetic{Operator: v.Arithmetic.Operator}}
+		if bound.Arithmetic.Left, err = b.value(v.Arithmetic.Left); err != nil {
+			return
+		}
+		bound.Arithmetic.Right, err = b.value(v.Arithmetic.Right)
+
+	default:
+		bound = v
+
+	}
+	return
+}
+
+func (b *binder) argument(arg interface{}) (bound *Value, err error) {
+	switch t := arg.(type) {
+
+	case nil:
+		bound = NilValue()
+
+	case string:
+		if b.rendered {
+			bound = &Value{String: &t}
+		} else {
+			bound = StringValue(t)
+		}
+
+	case *regexp.Regexp:
+		if b.rendered {
+			pattern := t.String()
+			bound = &Value{Regexp: &pattern}
+		} else {
+			bound = RegexpValue(t.String())
+		}
+
+	case bool:
+		bound = BoolValue(t)
+
+	case time.Time:
+		bound = TimeValue(t)
+
+	case time.Duration:
+		bound = DurationValue(t)
+
+	default:
+		rv := reflect.ValueOf(arg)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			bound = IntValue(int(rv.Int()))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if u := rv.Uint(); u > math.MaxInt64 {
+				// too large for an int, the same as when matching
+				bound = FloatValue(float64(u))
+			} else {
+				bound = IntValue(int(u))
+			}
+		case reflect.Float32, reflect.Float64:
+			bound = FloatValue(rv.Float())
+		case reflect.Slice, reflect.Array:
+			bound = &Value{List: make([]*Value, rv.Len())}
+			for idx := 0; idx < rv.Len(); idx++ {
+				if bound.List[idx], err = b.argument(rv.Index(idx).Interface()); err != nil {
+					return
+				}
+			}
+		default:
+			err = fmt.Errorf("unsupported argument type: %T", arg)
+		}
+
+	}
+	return
+}
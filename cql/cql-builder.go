@@ -0,0 +1,245 @@
+// Copyright (c) 2024  The Go-CoreLibs Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cql
+
+import (
+	"time"
+)
+
+// NewStatement validates the given expression and returns a new Statement
+// with the ContextKeys populated, the Statement is equivalent to one returned
+// by Compile for the Statement.String query
+//
+// Example:
+//
+//	stmnt, err := NewStatement(And(
+//	    Eq("Type", StringValue("page")),
+//	    Not(IsNull("Title")),
+//	))
+//	// stmnt.String() == `((.Type == 'page') AND (NOT (.Title IS NULL)))`
+func NewStatement(expr *Expression) (stmnt *Statement, err error) {
+	s := &Statement{Expression: expr}
+	if err = s.Validate(); err == nil {
+		s.ContextKeys = extractContextKeys(expr)
+		stmnt = s
+	}
+	return
+}
+
+// KeyValue returns a new context key Value
+func KeyValue(key string) *Value {
+	return &Value{ContextKey: &key}
+}
+
+// StringValue returns a new string literal Value, see QuoteString
+func StringValue(text string) *Value {
+	quoted := QuoteString(text)
+	return &Value{String: &quoted}
+}
+
+// RegexpValue returns a new regular expression Value, see QuoteRegexp
+func RegexpValue(pattern string) *Value {
+	quoted := QuoteRegexp(pattern)
+	return &Value{Regexp: &quoted}
+}
+
+// IntValue returns a new integer literal Value
+func IntValue(i int) *Value {
+	return &Value{Int: &i}
+}
+
+// FloatValue returns a new decimal literal Value
+func FloatValue(f float64) *Value {
+	return &Value{Float: &f}
+}
+
+// BoolValue returns a new boolean literal Value
+func BoolValue(b bool) *Value {
+	v := Boolean(b)
+	return &Value{Bool: &v}
+}
+
+// NilValue returns a new nil literal Value
+func NilValue() *Value {
+	v := Nil(true)
+	return &Value{Nil: &v}
+}
+
+// TimeValue returns a new time literal Value, which is date-only when the time
+// is midnight UTC
+func TimeValue(t time.Time) *Value {
+	text := formatTime(t)
+	return &Value{Time: &text}
+}
+
+// DurationValue returns a new duration literal Value
+func DurationValue(d time.Duration) *Value {
+	text := formatDuration(d)
+	return &Value{Duration: &text}
+}
+
+// ArithmeticValue returns a new Value applying the arithmetic operator to the
+// left and right operands, such as: ArithmeticValue(CallValue("now"), "-",
+// DurationValue(30*24*time.Hour)). Nested operands must follow the operator
+// precedence, see Arithmetic
+func ArithmeticValue(left *Value, operator string, right *Value) *Value {
+	return &Value{Arithmetic: &Arithmetic{Left: left, Operator: operator, Right: right}}
+}
+
+// SelfValue returns a new Value referring to the current item of an Any or All
+// expression, or to the whole context outside of them
+func SelfValue() *Value {
+	self := true
+	return &Value{Self: &self}
+}
+
+// ListValue returns a new list Value, for use with the IN operator
+func ListValue(items ...*Value) *Value {
+	if items == nil {
+		items = []*Value{}
+	}
+	return &Value{List: items}
+}
+
+// CallValue returns a new function call Value with the arguments given
+func CallValue(name string, args ...*Value) *Value {
+	return &Value{Call: &Call{Name: name, Args: args}}
+}
+
+// Op returns a new Operation Expression, comparing the context key with the
+// value using the operator given, see the specific builders such as Eq and In
+func Op(key string, operator Operator, value *Value) *Expression {
+	return OpValue(KeyValue(key), operator, value)
+}
+
+// OpValue is like Op with any left-hand operand, such as a CallValue
+func OpValue(left *Value, operator Operator, value *Value) *Expression {
+	return &Expression{Operation: &Operation{Left: left, Type: operator, Right: value}}
+}
+
+// Predicate returns a new Operation Expression testing the result of the
+// given CallValue, which must return a bool
+func Predicate(call *Value) *Expression {
+	return &Expression{Operation: &Operation{Left: call}}
+}
+
+// Eq returns a new == Operation Expression
+func Eq(key string, value *Value) *Expression {
+	return Op(key, "==", value)
+}
+
+// EqFold returns a new ==* (case-insensitive ==) Operation Expression
+func EqFold(key string, value *Value) *Expression {
+	return Op(key, "==*", value)
+}
+
+// Ne returns a new != Operation Expression
+func Ne(key string, value *Value) *Expression {
+	return Op(key, "!=", value)
+}
+
+// NeFold returns a new !=* (case-insensitive !=) Operation Expression
+func NeFold(key string, value *Value) *Expression {
+	return Op(key, "!=*", value)
+}
+
+// Match returns a new =~ Operation Expression
+func Match(key string, value *Value) *Expression {
+	return Op(key, "=~", value)
+}
+
+// NotMatch returns a new !~ Operation Expression
+func NotMatch(key string, value *Value) *Expression {
+	return Op(key, "!~", value)
+}
+
+// Lt returns a new < Operation Expression
+func Lt(key string, value *Value) *Expression {
+	return Op(key, "<", value)
+}
+
+// Le returns a new <= Operation Expression
+func Le(key string, value *Value) *Expression {
+	return Op(key, "<=", value)
+}
+
+// Gt returns a new > Operation Expression
+func Gt(key string, value *Value) *Expression {
+	return Op(key, ">", value)
+}
+
+// Ge returns a new >= Operation Expression
+func Ge(key string, value *Value) *Expression {
+	return Op(key, ">=", value)
+}
+
+// In returns a new IN Operation Expression with a list of the values given
+func In(key string, values ...*Value) *Expression {
+	return Op(key, "IN", ListValue(values...))
+}
+
+// IsNull returns a new IS NULL Operation Expression
+func IsNull(key string) *Expression {
+	return Op(key, "IS NULL", nil)
+}
+
+// IsNotNull returns a new IS NOT NULL Operation Expression
+func IsNotNull(key string) *Expression {
+	return Op(key, "IS NOT NULL", nil)
+}
+
+// Any returns a new ANY Quantifier Expression, matching when the expression
+// matches at least one item of the list at the context key
+func Any(key string, expr *Expression) *Expression {
+	return &Expression{Quantifier: &Quantifier{Type: "ANY", ContextKey: key, Expression: expr}}
+}
+
+// All returns a new ALL Quantifier Expression, matching when the expression
+// matches every item of the list at the context key
+func All(key string, expr *Expression) *Expression {
+	return &Expression{Quantifier: &Quantifier{Type: "ALL", ContextKey: key, Expression: expr}}
+}
+
+// And returns a new AND Condition Expression, more than two expressions are
+// chained from left to right, the same as the query syntax "a AND b AND c"
+func And(exprs ...*Expression) *Expression {
+	return chainConditions("AND", exprs)
+}
+
+// Or returns a new OR Condition Expression, more than two expressions are
+// chained from left to right, the same as the query syntax "a OR b OR c"
+func Or(exprs ...*Expression) *Expression {
+	return chainConditions("OR", exprs)
+}
+
+// Not returns a new NOT Condition Expression
+func Not(expr *Expression) *Expression {
+	return &Expression{Condition: &Condition{Type: "NOT", Right: expr}}
+}
+
+func chainConditions(kind string, exprs []*Expression) (expr *Expression) {
+	switch len(exprs) {
+	case 0:
+		// an invalid condition, reported by validation
+		return &Expression{Condition: &Condition{Type: kind}}
+	case 1:
+		return exprs[0]
+	}
+	expr = exprs[0]
+	for _, right := range exprs[1:] {
+		expr = &Expression{Condition: &Condition{Left: expr, Type: kind, Right: right}}
+	}
+	return
+}
@@ -0,0 +1,135 @@
+// Copyright (c) 2024  The Go-CoreLibs Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cql
+
+import (
+	"math"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBuilder(t *testing.T) {
+	Convey("NewStatement", t, func() {
+		for _, test := range []struct {
+			expr   *Expression
+			output string
+			keys   []string
+		}{
+			{
+				Eq("Title", StringValue("it's")),
+				`(.Title == "it's")`,
+				[]string{"Title"},
+			},
+			{
+				And(
+					In("Type", StringValue("page"), StringValue("post"), KeyValue("DefaultType")),
+					Not(IsNull("Author.Name")),
+					Or(Ge("Weight", IntValue(10)), Lt("Ratio", FloatValue(0.5))),
+				),
+				`(((.Type IN ('page', 'post', .DefaultType)) AND (NOT (.Author.Name IS NULL))) AND ((.Weight >= 10) OR (.Ratio < 0.5)))`,
+				[]string{"Author.Name", "DefaultType", "Ratio", "Type", "Weight"},
+			},
+			{
+				Or(Match("Path", RegexpValue("^/blog/")), NotMatch("Path", RegexpValue("!"))),
+				`((.Path =~ m!^/blog/!) OR (.Path !~ m/!/))`,
+				[]string{"Path"},
+			},
+			{
+				And(Ne("Draft", BoolValue(true)), Eq("Summary", NilValue()), IsNotNull("Title")),
+				`(((.Draft != true) AND (.Summary == nil)) AND (.Title IS NOT NULL))`,
+				[]string{"Draft", "Summary", "Title"},
+			},
+			{
+				Or(Eq("Title", StringValue(`both ' and "\n`)), Eq("Title", StringValue("über"))),
+				`((.Title == 'both \' and "\\n') OR (.Title == 'über'))`,
+				[]string{"Title"},
+			},
+			{
+				Match("Path", RegexpValue(`^/!@~\/$`)),
+				`(.Path =~ m/^\/!@~\/$/)`,
+				[]string{"Path"},
+			},
+			{
+				Or(EqFold("Category", StringValue("news")), NeFold("Category", KeyValue("Other"))),
+				`((.Category ==* 'news') OR (.Category !=* .Other))`,
+				[]string{"Category", "Other"},
+			},
+			{
+				Op("Items[0].Title", "<=", KeyValue("Other")),
+				`(.Items[0].Title <= .Other)`,
+				[]string{"Items[0].Title", "Other"},
+			},
+			{
+				And(
+					Predicate(CallValue("startsWith", KeyValue("Title"), StringValue("Go"))),
+					OpValue(CallValue("len", KeyValue("Tags")), ">", IntValue(2)),
+				),
+				`((startsWith(.Title, 'Go')) AND (len(.Tags) > 2))`,
+				[]string{"Tags", "Title"},
+			},
+		} {
+			stmnt, err := NewStatement(test.expr)
+			So(err, ShouldBeNil)
+			So(stmnt.String(), ShouldEqual, test.output)
+			So(stmnt.ContextKeys, ShouldEqual, test.keys)
+
+			parsed, pErr := Compile(stmnt.String())
+			So(pErr, ShouldBeNil)
+			So(parsed.String(), ShouldEqual, stmnt.String())
+			So(parsed.Stringify(), ShouldEqual, stmnt.Stringify())
+		}
+	})
+
+	Convey("Validation", t, func() {
+		invalidString, invalidEscape := `'unterminated`, `'\q'`
+		invalidRegexp, invalidFlags := `/missing-m/`, `m/x/z`
+		for _, expr := range []*Expression{
+			nil,
+			{},
+			Eq("Title", &Value{String: &invalidString}),
+			Eq("Title", &Value{String: &invalidEscape}),
+			Match("Path", &Value{Regexp: &invalidRegexp}),
+			Match("Path", &Value{Regexp: &invalidFlags}),
+			Eq("not a key", IntValue(1)),
+			Eq("Title", KeyValue("")),
+			Eq("Title", nil),
+			Eq("Title", &Value{}),
+			Eq("Title", ListValue(IntValue(1))),
+			Eq("Ratio", FloatValue(math.NaN())),
+			Gt("Ratio", FloatValue(math.Inf(1))),
+			In("Ratio", FloatValue(0.5), FloatValue(math.Inf(-1))),
+			Op("Title", "<>", IntValue(1)),
+			Op("Title", "IS NULL", IntValue(1)),
+			Predicate(KeyValue("Title")),
+			Predicate(CallValue("unknown", KeyValue("Title"))),
+			Predicate(CallValue("contains", KeyValue("Title"))),
+			Predicate(CallValue("contains", KeyValue("Title"), ListValue(IntValue(1)))),
+			OpValue(CallValue("len", KeyValue("Tags")), "", IntValue(1)),
+			OpValue(IntValue(1), "==", IntValue(1)),
+			In("Title"),
+			In("Title", ListValue(IntValue(1))),
+			And(),
+			And(Eq("A", IntValue(1)), nil),
+			{Condition: &Condition{Left: IsNull("A"), Type: "NOT", Right: IsNull("B")}},
+			{Condition: &Condition{Left: IsNull("A"), Type: "XOR", Right: IsNull("B")}},
+			{Condition: &Condition{Type: "NOT", Right: IsNull("B")}, Operation: IsNull("A").Operation},
+		} {
+			stmnt, err := NewStatement(expr)
+			So(err, ShouldNotBeNil)
+			So(stmnt, ShouldBeNil)
+		}
+	})
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2024  The Go-CoreLibs Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+// Call is a function call, used as an operand or, for functions returning a
+// bool, as a predicate on its own
+type Call struct {
+	Pos lexer.Position `parser:"" json:"-"`
+
+	Name string   `parser:"@Ident '('" json:"name"`
+	Args []*Value `parser:"( @@ ( ',' @@ )* )? ')'" json:"args,omitempty"`
+}
+
+// Clone returns a deep copy of this Call
+func (c *Call) Clone() (clone *Call) {
+	clone = &Call{Pos: c.Pos, Name: c.Name}
+	for _, arg := range c.Args {
+		clone.Args = append(clone.Args, arg.Clone())
+	}
+	return
+}
+
+// Render returns a copy of this Call with the arguments rendered
+func (c *Call) Render() (clone *Call) {
+	clone = &Call{Pos: c.Pos, Name: c.Name}
+	for _, arg := range c.Args {
+		clone.Args = append(clone.Args, arg.Render())
+	}
+	return
+}
+
+// format returns the query syntax for this Call, see Value.format
+func (c *Call) format(quoted bool) (text string) {
+	args := make([]string, len(c.Args))
+	for idx, arg := range c.Args {
+		args[idx] = arg.format(quoted)
+	}
+	text = c.Name + "(" + strings.Join(args, ", ") + ")"
+	return
+}
+
+// validate checks that the function is known, has the correct number of
+// arguments and that each argument is valid and of the type expected, as far
+// as the type is known before evaluation
+func (c *Call) validate(quoted bool) (err error) {
+	sig, known := LookupFunc(c.Name)
+	if !known {
+		err = atPosition(c.Pos, fmt.Errorf("unknown function %q", c.Name))
+		return
+	} else if len(c.Args) != len(sig.Args) {
+		err = atPosition(c.Pos, fmt.Errorf("%v expects %d argument(s), found %d", c.Name, len(sig.Args), len(c.Args)))
+		return
+	}
+	for idx, arg := range c.Args {
+		if arg == nil {
+			err = atPosition(c.Pos, fmt.Errorf("%v: missing argument", c.Name))
+		} else if arg.List != nil && sig.Args[idx] != ListType {
+			err = atPosition(arg.Pos, fmt.Errorf("%v does not accept a list", c.Name))
+		} else if err = arg.validate(quoted); err == nil {
+			if t := valueType(arg); !sig.Args[idx].accepts(t) {
+				err = atPosition(arg.Pos, fmt.Errorf("%v argument %d is of type %v, expected %v", c.Name, idx+1, t, sig.Args[idx]))
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
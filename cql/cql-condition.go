@@ -14,10 +14,12 @@
 
 package cql
 
+// Condition is a boolean operation on one or two expressions, Type is one of
+// AND, OR or NOT and for NOT conditions, only the Right expression is present
 type Condition struct {
-	Left  *Expression `parser:"'(' @@" json:"left"`
-	Type  string      `parser:"@( 'AND' | 'OR' )" json:"type"`
-	Right *Expression `parser:"@@ ')'" json:"right"`
+	Left  *Expression `json:"left,omitempty"`
+	Type  string      `json:"type"`
+	Right *Expression `json:"right"`
 }
 
 func (c *Condition) Render() (clone *Condition) {
@@ -31,3 +33,16 @@ func (c *Condition) Render() (clone *Condition) {
 	}
 	return
 }
+
+// Clone returns a deep copy of this Condition
+func (c *Condition) Clone() (clone *Condition) {
+	clone = new(Condition)
+	if c.Left != nil {
+		clone.Left = c.Left.Clone()
+	}
+	clone.Type = c.Type
+	if c.Right != nil {
+		clone.Right = c.Right.Clone()
+	}
+	return
+}
@@ -15,16 +15,53 @@
 package cql
 
 import (
+	"errors"
 	"fmt"
-	"math"
-	"strconv"
+	"regexp"
 	"strings"
+
+	"github.com/alecthomas/participle/v2"
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+var (
+	rxExpected = regexp.MustCompile(`\(expected (.+)\)$`)
+	// rxTokenType matches the lexer token types participle describes when it
+	// expected one of many alternatives, such as "<duration>"
+	rxTokenType = regexp.MustCompile(`^<[a-z]+>$`)
+	// rxLeadingToken matches the quoted token at the start of a grammar rule
+	// participle describes, such as the "(" of `"(" Expression ")"`
+	rxLeadingToken = regexp.MustCompile(`^"(?:\\.|[^"\\])*"`)
+	// gGrammarNames replaces the names of the unexported grammar types which
+	// participle uses when describing the expected tokens
+	gGrammarNames = strings.NewReplacer(
+		"Disjunction", "Expression",
+		"Conjunction", "Expression",
+		"Negation", "Expression",
+		"Primary", "Expression",
+		"Comparison", "Operation",
+		"Operand", "Value",
+		"Multiplicative", "Value",
+	)
 )
 
+// ParseError describes a query which could not be compiled
 type ParseError struct {
-	err     error
-	Query   string
-	Column  int
+	err error
+	// Query is the query text, with leading and trailing space removed
+	Query string
+	// Line and Column are the 1-based position of the error, Column is -1
+	// when the error has no position
+	Line   int
+	Column int
+	// Offset is the 0-based byte offset of the error within the Query
+	Offset int
+	// Token is the offending token, which is empty at the end of the Query
+	Token string
+	// Expected describes the tokens the parser expected instead of Token,
+	// if known, such as "NULL" or the grammar-level Expression or Value
+	Expected string
+	// Message is the error message, without the position
 	Message string
 }
 
@@ -36,33 +73,230 @@ func newParseError(query string, participleError error) (e *ParseError) {
 		err:     participleError,
 		Query:   query,
 		Column:  -1,
-		Message: "",
+		Message: participleError.Error(),
 	}
-	if parts := strings.Split(e.err.Error(), ":"); len(parts) == 4 {
-		var sCol, sMsg = parts[2], strings.TrimSpace(parts[3])
-		if col, err := strconv.Atoi(sCol); err == nil {
-			e.Column = col
+
+	var pErr participle.Error
+	if !errors.As(participleError, &pErr) {
+		return
+	}
+	e.Message = gGrammarNames.Replace(pErr.Message())
+	if pos := pErr.Position(); pos.Line > 0 {
+		e.Line, e.Column, e.Offset = pos.Line, pos.Column, pos.Offset
+	}
+
+	var unexpected *participle.UnexpectedTokenError
+	if errors.As(participleError, &unexpected) {
+		if !unexpected.Unexpected.EOF() {
+			e.Token = unexpected.Unexpected.Value
+			if unexpected.Unexpected.Type == gLexer.Symbols()["Key"] {
+				// the token was mapped without the leading period
+				e.Token = "." + e.Token
+				e.Message = strings.Replace(e.Message, fmt.Sprintf("%q", unexpected.Unexpected.Value), fmt.Sprintf("%q", e.Token), 1)
+			}
+		}
+		if e.Expected = gGrammarNames.Replace(unexpected.Expect); e.Expected == "" {
+			if m := rxExpected.FindStringSubmatch(e.Message); m != nil {
+				e.Expected = m[1]
+			}
 		}
-		e.Message = sMsg
+		if expected := normalizeExpected(query, e.Offset, e.Expected); expected != e.Expected {
+			e.Message = strings.Replace(e.Message, "(expected "+e.Expected+")", "(expected "+expected+")", 1)
+			e.Expected = expected
+		}
+	} else if e.Column > 0 && e.Offset < len(query) {
+		// lexer and validation errors, the token is the one at the position
+		e.Token = tokenAt(query, e.Offset)
 	}
 	return
 }
 
-func (e *ParseError) Error() (msg string) {
-	if e.err != nil {
-		msg = e.err.Error()
+// normalizeExpected returns the expected description as a single token or
+// grammar-level name, participle describes either the last alternative it
+// tried, such as "<duration>", or the whole grammar rule, such as
+// `"(" (Value ("," Value)*)? ")"`, of which only the leading token is the
+// one expected
+func normalizeExpected(query string, offset int, expected string) string {
+	switch {
+	case expected == "", expected == "Expression", expected == "Operation", expected == "Value":
+	case rxTokenType.MatchString(expected):
+		expected = expectedAt(query, offset)
+	default:
+		if leading := rxLeadingToken.FindString(expected); leading != "" {
+			expected = leading
+		} else {
+			expected = expectedAt(query, offset)
+		}
+	}
+	return expected
+}
+
+// significantTokens returns the tokens of the query text without whitespace
+// and EOF, or nil if the text is not valid
+func significantTokens(text string) (tokens []lexer.Token) {
+	lex, err := gLexer.LexString("cql", text)
+	if err != nil {
+		return
+	}
+	all, err := lexer.ConsumeAll(lex)
+	if err != nil {
+		return
+	}
+	whitespace := gLexer.Symbols()["whitespace"]
+	for _, token := range all {
+		if !token.EOF() && token.Type != whitespace {
+			tokens = append(tokens, token)
+		}
+	}
+	return
+}
+
+// tokenAt returns the token at the offset within the query, or the text at
+// the offset up to the next space when the text is not a valid token
+func tokenAt(query string, offset int) (token string) {
+	if lex, err := gLexer.LexString("cql", query[offset:]); err == nil {
+		if next, err := lex.Next(); err == nil && !next.EOF() && next.Type != gLexer.Symbols()["whitespace"] {
+			return next.Value
+		}
+	}
+	token = query[offset:]
+	if end := strings.IndexAny(token, " \t\r\n"); end > 0 {
+		token = token[:end]
+	}
+	return
+}
+
+// expectedAt returns the grammar-level name of what the parser expected at the
+// offset within the query, which is an Expression at the start of the query,
+// after AND, OR, NOT and the opening parenthesis of a group or quantifier and
+// is a Value everywhere else
+func expectedAt(query string, offset int) (expected string) {
+	isExpression := func(token lexer.Token) bool {
+		switch strings.ToUpper(token.Value) {
+		case "AND", "OR", "NOT":
+			return token.Type == gLexer.Symbols()["Keyword"]
+		}
+		return false
+	}
+	tokens := significantTokens(query[:offset])
+	last := len(tokens) - 1
+	switch {
+	case last < 0, isExpression(tokens[last]):
+		return "Expression"
+	case tokens[last].Value == "(":
+		if last == 0 || isExpression(tokens[last-1]) || tokens[last-1].Value == "(" || tokens[last-1].Type == gLexer.Symbols()["Key"] {
+			return "Expression"
+		}
+	}
+	return "Value"
+}
+
+// positionOf returns the lexer.Position of the byte offset within the query
+func positionOf(query string, offset int) (pos lexer.Position) {
+	pos = lexer.Position{Filename: "cql", Offset: offset, Line: 1, Column: 1}
+	for _, r := range query[:offset] {
+		if r == '\n' {
+			pos.Line += 1
+			pos.Column = 1
+		} else {
+			pos.Column += 1
+		}
 	}
 	return
 }
 
+// Error returns the Message prefixed with the Line and Column, if known
+func (e *ParseError) Error() (msg string) {
+	if e.Column < 0 {
+		return e.Message
+	}
+	return fmt.Sprintf("cql:%d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+// Unwrap returns the underlying error, such as a LimitError
+func (e *ParseError) Unwrap() error {
+	return e.err
+}
+
+// Pretty returns the line of the Query with the error and a marker pointing
+// at the Column, multi-line queries have the lines prefixed with their line
+// numbers
+//
+// Example:
+//
+//	(.Title == 'one' OR .Title ~= 'two')
+//	                           ^- error: invalid input text "~= 'two')"
 func (e *ParseError) Pretty() (refined string) {
 	if e.Column == -1 {
-		refined = fmt.Sprintf("internal error: %v", e.err.Error())
+		refined = fmt.Sprintf("internal error: %v", e.Message)
 		return
 	}
-	col := int(math.Max(float64(e.Column-1), 0))
-	indent := strings.Repeat(" ", col)
+
+	lines := strings.Split(e.Query, "\n")
+	idx := e.Line - 1
+	if idx < 0 {
+		idx = 0
+	} else if idx >= len(lines) {
+		idx = len(lines) - 1
+	}
+	line := lines[idx]
+
+	var gutter string
+	if len(lines) > 1 {
+		gutter = fmt.Sprintf("%d | ", e.Line)
+	}
+
+	// indent with the same whitespace as the line so tabs stay aligned
+	var indent strings.Builder
+	indent.WriteString(strings.Repeat(" ", len(gutter)))
+	for count, r := range []rune(line) {
+		if count >= e.Column-1 {
+			break
+		} else if r == '\t' {
+			indent.WriteRune('\t')
+		} else {
+			indent.WriteRune(' ')
+		}
+	}
+	for extra := e.Column - 1 - len([]rune(line)); extra > 0; extra-- {
+		indent.WriteRune(' ')
+	}
+
 	message := fmt.Sprintf("error: %v", e.Message)
-	refined = fmt.Sprintf("%v\n%v^- %v\n", e.Query, indent, message)
+	refined = fmt.Sprintf("%v%v\n%v^- %v\n", gutter, line, indent.String(), message)
 	return
 }
+
+// positionError is a validation error of a specific value of a statement
+type positionError struct {
+	pos lexer.Position
+	err error
+}
+
+func (e *positionError) Error() string {
+	return e.err.Error()
+}
+
+func (e *positionError) Unwrap() error {
+	return e.err
+}
+
+// atPosition returns the error given with the position given, unless the
+// position is unknown or the error already has a position
+func atPosition(pos lexer.Position, err error) error {
+	var pe *positionError
+	if err == nil || pos.Line <= 0 || errors.As(err, &pe) {
+		return err
+	}
+	return &positionError{pos: pos, err: err}
+}
+
+// errorPosition returns the position of the given error, or the position
+// given when the error has none
+func errorPosition(err error, pos lexer.Position) lexer.Position {
+	var pe *positionError
+	if errors.As(err, &pe) {
+		return pe.pos
+	}
+	return pos
+}
@@ -15,8 +15,9 @@
 package cql
 
 type Expression struct {
-	Condition *Condition `parser:"( @@ )?" json:"condition,omitempty"`
-	Operation *Operation `parser:"( @@ )?" json:"operation,omitempty"`
+	Condition  *Condition  `json:"condition,omitempty"`
+	Operation  *Operation  `json:"operation,omitempty"`
+	Quantifier *Quantifier `json:"quantifier,omitempty"`
 }
 
 func (e *Expression) Render() (clone *Expression) {
@@ -27,5 +28,23 @@ func (e *Expression) Render() (clone *Expression) {
 	if e.Operation != nil {
 		clone.Operation = e.Operation.Render()
 	}
+	if e.Quantifier != nil {
+		clone.Quantifier = e.Quantifier.Render()
+	}
+	return
+}
+
+// Clone returns a deep copy of this Expression
+func (e *Expression) Clone() (clone *Expression) {
+	clone = new(Expression)
+	if e.Condition != nil {
+		clone.Condition = e.Condition.Clone()
+	}
+	if e.Operation != nil {
+		clone.Operation = e.Operation.Clone()
+	}
+	if e.Quantifier != nil {
+		clone.Quantifier = e.Quantifier.Clone()
+	}
 	return
 }
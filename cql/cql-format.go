@@ -0,0 +1,164 @@
+// Copyright (c) 2024  The Go-CoreLibs Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cql
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	rxKeyParts = regexp.MustCompile(gSegment + `|\[\d+\]`)
+)
+
+// Format returns the canonical query syntax for this Statement, statements
+// with the same canonical form are semantically equivalent. The canonical form
+// is a valid query which:
+//
+//   - quotes strings and regular expressions the same as QuoteString and
+//     QuoteRegexp, with any regexp flags moved into the pattern
+//   - quotes context key segments only when required, see QuoteKey
+//   - uses upper-case keywords and lower-case true, false and nil
+//   - orders the context keys of equality operations, so that .B == .A is
+//     formatted as .A == .B
+//   - flattens chains of AND and OR conditions and sorts their operands
+//
+// As the AND and OR operands are sorted, compiling the canonical form may
+// change the order of evaluation, see Context.MatchQL
+func (s *Statement) Format() (query string) {
+	if s.Expression == nil {
+		return
+	}
+	expr := s.Expression
+	if !s.rendered {
+		expr = expr.Render()
+	}
+	query = formatExpression(expr)
+	return
+}
+
+// Equal returns true if this Statement and the other are semantically
+// equivalent, having the same canonical form, see Format
+func (s *Statement) Equal(other *Statement) bool {
+	if s == nil || other == nil {
+		return s == other
+	}
+	return s.Format() == other.Format()
+}
+
+// formatExpression returns the canonical form of the rendered expression
+func formatExpression(expr *Expression) (query string) {
+	switch {
+
+	case expr.Operation != nil:
+		op := expr.Operation
+		left := formatValue(op.Left)
+		switch {
+		case op.Type == "":
+			query = fmt.Sprintf("(%s)", left)
+			return
+		case op.Right == nil:
+			query = fmt.Sprintf("(%s %s)", left, op.Type)
+			return
+		}
+		right := formatValue(op.Right)
+		if op.Right.ContextKey != nil || op.Right.Call != nil {
+			if (op.Type == "==" || op.Type == "!=" || op.Type == "==*" || op.Type == "!=*") && right < left {
+				left, right = right, left
+			}
+		}
+		query = fmt.Sprintf("(%s %s %s)", left, op.Type, right)
+
+	case expr.Quantifier != nil:
+		quant := expr.Quantifier
+		query = "(" + quant.Type + " ." + formatKey(quant.ContextKey) + " " + formatExpression(quant.Expression) + ")"
+
+	case expr.Condition != nil && strings.ToUpper(expr.Condition.Type) == "NOT":
+		query = "(NOT " + formatExpression(expr.Condition.Right) + ")"
+
+	case expr.Condition != nil:
+		kind := strings.ToUpper(expr.Condition.Type)
+		var operands []string
+		var flatten func(expr *Expression)
+		flatten = func(expr *Expression) {
+			if cond := expr.Condition; cond != nil && strings.ToUpper(cond.Type) == kind {
+				flatten(cond.Left)
+				flatten(cond.Right)
+				return
+			}
+			operands = append(operands, formatExpression(expr))
+		}
+		flatten(expr)
+		sort.Strings(operands)
+		query = "(" + strings.Join(operands, " "+kind+" ") + ")"
+
+	}
+	return
+}
+
+// FormatValue returns the canonical query syntax for the given rendered value,
+// see Statement.Format
+func FormatValue(v *Value) (text string) {
+	return formatValue(v)
+}
+
+// formatValue returns the canonical form of the rendered value
+func formatValue(v *Value) (text string) {
+	switch {
+	case v.ContextKey != nil:
+		text = "." + formatKey(*v.ContextKey)
+	case v.List != nil:
+		items := make([]string, len(v.List))
+		for idx, item := range v.List {
+			items[idx] = formatValue(item)
+		}
+		text = "(" + strings.Join(items, ", ") + ")"
+	case v.Call != nil:
+		args := make([]string, len(v.Call.Args))
+		for idx, arg := range v.Call.Args {
+			args[idx] = formatValue(arg)
+		}
+		text = v.Call.Name + "(" + strings.Join(args, ", ") + ")"
+	case v.Arithmetic != nil:
+		text = formatValue(v.Arithmetic.Left) + " " + v.Arithmetic.Operator + " " + formatValue(v.Arithmetic.Right)
+	default:
+		text = v.format(false)
+	}
+	return
+}
+
+// formatKey returns the given context key with each segment quoted only when
+// required
+func formatKey(key string) (formatted string) {
+	for idx, part := range rxKeyParts.FindAllString(key, -1) {
+		switch {
+		case part[0] == '[':
+			formatted += part
+			continue
+		case part[0] == '"':
+			if unquoted, err := strconv.Unquote(part); err == nil {
+				part = unquoted
+			}
+		}
+		if idx > 0 {
+			formatted += "."
+		}
+		formatted += QuoteKey(part)
+	}
+	return
+}
@@ -0,0 +1,175 @@
+// Copyright (c) 2024  The Go-CoreLibs Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cql
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+// Type is the type of a function argument or result, as far as it can be
+// known when a query is compiled
+type Type string
+
+const (
+	// AnyType accepts any value
+	AnyType Type = "any"
+	// StringType accepts string values, including regexp patterns
+	StringType Type = "string"
+	// NumberType accepts integer and decimal values
+	NumberType Type = "number"
+	// BoolType accepts true and false
+	BoolType Type = "bool"
+	// ListType accepts lists, a literal list argument requires this type
+	ListType Type = "list"
+	// TimeType accepts time.Time values
+	TimeType Type = "time"
+	// DurationType accepts time.Duration values
+	DurationType Type = "duration"
+)
+
+// accepts returns true if a value of the other Type can be used where this
+// Type is expected, AnyType accepts and is accepted by everything as the type
+// of context keys and placeholders is not known until evaluation
+func (t Type) accepts(other Type) bool {
+	return t == AnyType || other == AnyType || t == other
+}
+
+// valid returns true if this is one of the Type constants
+func (t Type) valid() bool {
+	switch t {
+	case AnyType, StringType, NumberType, BoolType, ListType, TimeType, DurationType:
+		return true
+	}
+	return false
+}
+
+// Signature describes the arguments and result of a function callable from
+// queries
+type Signature struct {
+	// Args are the types of the arguments, which are all required
+	Args []Type
+	// Result is the type of the value returned
+	Result Type
+}
+
+var (
+	rxValidFuncName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+	rxKeywordName   = regexp.MustCompile(`(?i)^(?:TRUE|FALSE|NULL|NIL|IS|NOT|AND|OR|IN|ANY|ALL)$`)
+)
+
+var (
+	// gBuiltins are the functions built into the query language, see
+	// Context.MatchQL for their descriptions
+	gBuiltins = map[string]Signature{
+		"contains":   {Args: []Type{AnyType, AnyType}, Result: BoolType},
+		"startsWith": {Args: []Type{StringType, StringType}, Result: BoolType},
+		"endsWith":   {Args: []Type{StringType, StringType}, Result: BoolType},
+		"len":        {Args: []Type{AnyType}, Result: NumberType},
+		"lower":      {Args: []Type{StringType}, Result: StringType},
+		"upper":      {Args: []Type{StringType}, Result: StringType},
+		"now":        {Result: TimeType},
+	}
+	gFunctions = make(map[string]Signature)
+	gFuncLock  = &sync.RWMutex{}
+)
+
+// RegisterFunc adds a named function to the query language, queries calling
+// it are checked against the given Signature by Compile. Function names are
+// case-sensitive, cannot be keywords and cannot replace built-in or previously
+// registered functions
+//
+// RegisterFunc only makes the function known to the parser, the evaluation is
+// provided by the query engine, see context.RegisterQueryFunc
+func RegisterFunc(name string, sig Signature) (err error) {
+	if !rxValidFuncName.MatchString(name) || rxKeywordName.MatchString(name) {
+		err = fmt.Errorf("invalid function name %q", name)
+		return
+	}
+	for idx, arg := range sig.Args {
+		if !arg.valid() {
+			err = fmt.Errorf("%v: invalid type %q for argument %d", name, arg, idx+1)
+			return
+		}
+	}
+	if !sig.Result.valid() {
+		err = fmt.Errorf("%v: invalid result type %q", name, sig.Result)
+		return
+	}
+
+	gFuncLock.Lock()
+	defer gFuncLock.Unlock()
+	if _, present := gBuiltins[name]; present {
+		err = fmt.Errorf("function %q is built-in", name)
+	} else if _, present = gFunctions[name]; present {
+		err = fmt.Errorf("function %q is already registered", name)
+	} else {
+		gFunctions[name] = Signature{Args: append([]Type{}, sig.Args...), Result: sig.Result}
+	}
+	return
+}
+
+// UnregisterFunc removes the named function added by RegisterFunc, returning
+// false when it was not registered. Built-in functions cannot be removed and
+// compiled statements calling the function are not affected
+func UnregisterFunc(name string) (ok bool) {
+	gFuncLock.Lock()
+	defer gFuncLock.Unlock()
+	if _, ok = gFunctions[name]; ok {
+		delete(gFunctions, name)
+	}
+	return
+}
+
+// LookupFunc returns the Signature of the named built-in or registered
+// function
+func LookupFunc(name string) (sig Signature, ok bool) {
+	if sig, ok = gBuiltins[name]; ok {
+		return
+	}
+	gFuncLock.RLock()
+	defer gFuncLock.RUnlock()
+	sig, ok = gFunctions[name]
+	return
+}
+
+// valueType returns the Type of the given value as known before evaluation
+func valueType(v *Value) (t Type) {
+	switch {
+	case v.String != nil, v.Regexp != nil:
+		t = StringType
+	case v.Int != nil, v.Float != nil:
+		t = NumberType
+	case v.Bool != nil:
+		t = BoolType
+	case v.List != nil:
+		t = ListType
+	case v.Time != nil:
+		t = TimeType
+	case v.Duration != nil:
+		t = DurationType
+	case v.Arithmetic != nil:
+		t, _ = v.Arithmetic.resultType()
+	case v.Call != nil:
+		t = AnyType
+		if sig, ok := LookupFunc(v.Call.Name); ok {
+			t = sig.Result
+		}
+	default:
+		t = AnyType
+	}
+	return
+}
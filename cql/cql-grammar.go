@@ -0,0 +1,153 @@
+// Copyright (c) 2024  The Go-CoreLibs Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cql
+
+import (
+	"strings"
+
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+// The boolean grammar is parsed into these intermediate types, which encode
+// the operator precedence (NOT, then AND, then OR), and are then folded into
+// the binary Expression and Condition trees the rest of the package uses
+
+type disjunction struct {
+	Left  *conjunction   `parser:"@@"`
+	Right []*conjunction `parser:"( 'OR' @@ )*"`
+}
+
+type conjunction struct {
+	Left  *negation   `parser:"@@"`
+	Right []*negation `parser:"( 'AND' @@ )*"`
+}
+
+type negation struct {
+	Not     *negation `parser:"  'NOT' @@"`
+	Primary *primary  `parser:"| @@"`
+}
+
+type primary struct {
+	Quantifier *quantifier  `parser:"  @@"`
+	Group      *disjunction `parser:"| '(' @@ ')'"`
+	Operation  *comparison  `parser:"| @@"`
+}
+
+type quantifier struct {
+	Pos lexer.Position
+
+	Type       string       `parser:"@( 'ANY' | 'ALL' )"`
+	ContextKey string       `parser:"@Key"`
+	Expression *disjunction `parser:"'(' @@ ')'"`
+}
+
+// The operands of comparisons are parsed into these intermediate types, which
+// encode the arithmetic precedence (*, / and %, then + and -), and are folded
+// into Values with Arithmetic applied from left to right
+
+type comparison struct {
+	Pos lexer.Position
+
+	Left  *operand `parser:" @@"`
+	Type  Operator `parser:"  ( ( @'IS' @'NOT'? @'NULL' | ( @'IN' | @'!=*' | @'==*' | @'!=' | @'==' | @'=~' | @'!~' | @'<=' | @'>=' | @'<' | @'>' )"`
+	Right *operand `parser:"  @@ ) )?"`
+}
+
+type operand struct {
+	Left  *multiplicative `parser:"@@"`
+	Right []*operandTail  `parser:"@@*"`
+}
+
+type operandTail struct {
+	Operator string          `parser:"@( '+' | '-' )"`
+	Value    *multiplicative `parser:"@@"`
+}
+
+type multiplicative struct {
+	Left  *Value                `parser:"@@"`
+	Right []*multiplicativeTail `parser:"@@*"`
+}
+
+type multiplicativeTail struct {
+	Operator string `parser:"@( '*' | '/' | '%' )"`
+	Value    *Value `parser:"@@"`
+}
+
+func (d *disjunction) expression() (expr *Expression) {
+	expr = d.Left.expression()
+	for _, right := range d.Right {
+		expr = &Expression{Condition: &Condition{Left: expr, Type: "OR", Right: right.expression()}}
+	}
+	return
+}
+
+func (c *conjunction) expression() (expr *Expression) {
+	expr = c.Left.expression()
+	for _, right := range c.Right {
+		expr = &Expression{Condition: &Condition{Left: expr, Type: "AND", Right: right.expression()}}
+	}
+	return
+}
+
+func (n *negation) expression() (expr *Expression) {
+	if n.Not != nil {
+		expr = &Expression{Condition: &Condition{Type: "NOT", Right: n.Not.expression()}}
+		return
+	}
+	expr = n.Primary.expression()
+	return
+}
+
+func (p *primary) expression() (expr *Expression) {
+	if p.Quantifier != nil {
+		expr = &Expression{Quantifier: &Quantifier{
+			Pos:        p.Quantifier.Pos,
+			Type:       strings.ToUpper(p.Quantifier.Type),
+			ContextKey: p.Quantifier.ContextKey,
+			Expression: p.Quantifier.Expression.expression(),
+		}}
+		return
+	}
+	if p.Group != nil {
+		expr = p.Group.expression()
+		return
+	}
+	expr = &Expression{Operation: p.Operation.operation()}
+	return
+}
+
+func (c *comparison) operation() (op *Operation) {
+	op = &Operation{Pos: c.Pos, Left: c.Left.value(), Type: c.Type}
+	if c.Right != nil {
+		op.Right = c.Right.value()
+	}
+	return
+}
+
+func (o *operand) value() (v *Value) {
+	v = o.Left.value()
+	for _, tail := range o.Right {
+		v = &Value{Pos: v.Pos, Arithmetic: &Arithmetic{Left: v, Operator: tail.Operator, Right: tail.Value.value()}}
+	}
+	return
+}
+
+func (m *multiplicative) value() (v *Value) {
+	v = m.Left
+	for _, tail := range m.Right {
+		v = &Value{Pos: v.Pos, Arithmetic: &Arithmetic{Left: v, Operator: tail.Operator, Right: tail.Value}}
+	}
+	return
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2024  The Go-CoreLibs Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cql
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// CompileJSON is like Compile for the JSON form of a Statement, as returned by
+// Statement.Stringify, enforcing the DefaultLimits
+//
+// The Statement returned is in query syntax form, the same as one returned by
+// Compile, validated and has the ContextKeys recomputed, any context-keys
+// present in the JSON are ignored
+func CompileJSON(data []byte) (stmnt *Statement, err error) {
+	return CompileJSONWithLimits(data, DefaultLimits)
+}
+
+// CompileJSONWithLimits is like CompileJSON and enforces the given Limits
+// instead of the DefaultLimits
+func CompileJSONWithLimits(data []byte, limits Limits) (stmnt *Statement, err error) {
+	s := &Statement{limits: limits}
+	if err = json.Unmarshal(data, s); err == nil {
+		stmnt = s
+	}
+	return
+}
+
+// UnmarshalJSON loads the JSON form of a Statement, see CompileJSON, keeping
+// the Limits of this Statement
+func (s *Statement) UnmarshalJSON(data []byte) (err error) {
+	var decoded struct {
+		Expression *Expression `json:"expressions"`
+	}
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		err = fmt.Errorf("error decoding statement: %w", err)
+		return
+	}
+
+	loaded := &Statement{Expression: decoded.Expression, limits: s.limits}
+	if loaded.Expression != nil {
+		// the JSON form has rendered values
+		quoteValues(loaded.Expression)
+	}
+	if err = loaded.Validate(); err != nil {
+		err = fmt.Errorf("invalid statement: %w", err)
+		return
+	}
+	if pErr := loaded.limits.checkExpression(loaded.Expression, true); pErr != nil {
+		err = pErr
+		return
+	}
+	loaded.ContextKeys = extractContextKeys(loaded.Expression)
+	*s = *loaded
+	return
+}
@@ -0,0 +1,226 @@
+// Copyright (c) 2024  The Go-CoreLibs Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alecthomas/participle/v2"
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+// Limits are the resource limits enforced on statements compiled from
+// untrusted query text, a zero (or negative) field is unlimited
+type Limits struct {
+	// MaxLength is the maximum length of the query text, in bytes
+	MaxLength int
+	// MaxDepth is the maximum nesting depth of the parsed statement, where
+	// each NOT, ANY, ALL, operation, arithmetic operator and function call is
+	// one level deeper than its parent, as is each AND and OR except when
+	// chained with the same operator (the length of chains is limited by
+	// MaxOperations)
+	MaxDepth int
+	// MaxOperations is the maximum number of operations
+	MaxOperations int
+	// MaxRegexpLength is the maximum length of regular expression patterns,
+	// including string patterns used with =~ and !~ and any patterns read
+	// from context values during evaluation
+	MaxRegexpLength int
+}
+
+// DefaultLimits are the Limits used by Compile, which are unlimited unless
+// changed by the application
+var DefaultLimits Limits
+
+// LimitError is the error reported when a statement exceeds one of its Limits
+type LimitError struct {
+	// Pos is the position of the query text which exceeded the limit
+	Pos lexer.Position
+	// Limit is the name of the Limits field exceeded
+	Limit string
+	// Max is the configured limit
+	Max int
+	// Size is the actual size, which is only the size reached when parsing
+	// stopped for MaxDepth and MaxOperations
+	Size int
+}
+
+func (e *LimitError) Error() string {
+	return participle.FormatError(e)
+}
+
+func (e *LimitError) Message() string {
+	return fmt.Sprintf("%v of %d exceeds the limit of %d", limitNames[e.Limit], e.Size, e.Max)
+}
+
+func (e *LimitError) Position() lexer.Position {
+	return e.Pos
+}
+
+var limitNames = map[string]string{
+	"MaxLength":       "query length",
+	"MaxDepth":        "nesting depth",
+	"MaxOperations":   "number of operations",
+	"MaxRegexpLength": "regexp length",
+}
+
+// CheckRegexp returns a LimitError if the given pattern exceeds MaxRegexpLength
+func (l Limits) CheckRegexp(pattern string) (err error) {
+	if l.MaxRegexpLength > 0 && len(pattern) > l.MaxRegexpLength {
+		err = &LimitError{Limit: "MaxRegexpLength", Max: l.MaxRegexpLength, Size: len(pattern)}
+	}
+	return
+}
+
+// checkQuery checks the query text against MaxLength, before the query is
+// parsed
+func (l Limits) checkQuery(query string) (err participle.Error) {
+	if l.MaxLength > 0 && len(query) > l.MaxLength {
+		err = &LimitError{Pos: positionOf(query, l.MaxLength), Limit: "MaxLength", Max: l.MaxLength, Size: len(query)}
+	}
+	return
+}
+
+// checkExpression checks the parsed expression against MaxDepth,
+// MaxOperations and MaxRegexpLength, quoted is the same as for
+// validateExpression
+func (l Limits) checkExpression(expr *Expression, quoted bool) (err participle.Error) {
+	var operations int
+	// chain is the AND or OR type of the parent condition, which the same
+	// type of condition continues at the same depth
+	var check func(expr *Expression, depth int, chain string) participle.Error
+	check = func(expr *Expression, depth int, chain string) participle.Error {
+		if expr == nil {
+			return nil
+		}
+		if chain == "" || expr.Condition == nil || !strings.EqualFold(expr.Condition.Type, chain) {
+			if depth += 1; l.MaxDepth > 0 && depth > l.MaxDepth {
+				return &LimitError{Pos: expressionPos(expr), Limit: "MaxDepth", Max: l.MaxDepth, Size: depth}
+			}
+		}
+		switch {
+		case expr.Operation != nil:
+			if operations += 1; l.MaxOperations > 0 && operations > l.MaxOperations {
+				return &LimitError{Pos: expr.Operation.Pos, Limit: "MaxOperations", Max: l.MaxOperations, Size: operations}
+			}
+			for _, v := range []*Value{expr.Operation.Left, expr.Operation.Right} {
+				if e := l.checkValueDepth(v, depth, expr.Operation.Pos); e != nil {
+					return e
+				}
+			}
+			if e := l.checkOperation(expr.Operation, quoted); e != nil {
+				return e
+			}
+		case expr.Quantifier != nil:
+			return check(expr.Quantifier.Expression, depth, "")
+		case expr.Condition != nil:
+			if chain = strings.ToUpper(expr.Condition.Type); chain == "NOT" {
+				chain = ""
+			}
+			if e := check(expr.Condition.Left, depth, chain); e != nil {
+				return e
+			}
+			return check(expr.Condition.Right, depth, chain)
+		}
+		return nil
+	}
+	err = check(expr, 0, "")
+	return
+}
+
+// checkValueDepth checks the nesting of arithmetic and function calls within
+// the given operand against MaxDepth, depth is the depth of the operation
+func (l Limits) checkValueDepth(v *Value, depth int, pos lexer.Position) (err participle.Error) {
+	if v == nil || l.MaxDepth <= 0 {
+		return
+	}
+	var children []*Value
+	switch {
+	case v.Arithmetic != nil:
+		children = []*Value{v.Arithmetic.Left, v.Arithmetic.Right}
+	case v.Call != nil:
+		children = v.Call.Args
+	case v.List != nil:
+		for _, item := range v.List {
+			if err = l.checkValueDepth(item, depth, pos); err != nil {
+				return
+			}
+		}
+		return
+	default:
+		return
+	}
+	if depth += 1; depth > l.MaxDepth {
+		err = &LimitError{Pos: pos, Limit: "MaxDepth", Max: l.MaxDepth, Size: depth}
+		return
+	}
+	for _, child := range children {
+		if err = l.checkValueDepth(child, depth, pos); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// expressionPos returns the position of the first operation or quantifier of
+// the given expression
+func expressionPos(expr *Expression) (pos lexer.Position) {
+	switch {
+	case expr.Operation != nil:
+		pos = expr.Operation.Pos
+	case expr.Quantifier != nil:
+		pos = expr.Quantifier.Pos
+	case expr.Condition != nil && expr.Condition.Left != nil:
+		pos = expressionPos(expr.Condition.Left)
+	case expr.Condition != nil && expr.Condition.Right != nil:
+		pos = expressionPos(expr.Condition.Right)
+	}
+	return
+}
+
+// checkOperation checks the sizes of the regular expression patterns of the
+// given operation
+func (l Limits) checkOperation(op *Operation, quoted bool) (err participle.Error) {
+	if l.MaxRegexpLength <= 0 || op.Right == nil {
+		return
+	}
+	items := op.Right.List
+	if items == nil {
+		items = []*Value{op.Right}
+	}
+	for _, item := range items {
+		var pattern string
+		switch {
+		case item.Regexp != nil:
+			if pattern = *item.Regexp; quoted {
+				pattern, _ = UnquoteRegexp(pattern)
+			}
+		case item.String != nil && (op.Type == "=~" || op.Type == "!~"):
+			if pattern = *item.String; quoted {
+				pattern, _ = UnquoteString(pattern)
+			}
+		default:
+			continue
+		}
+		if e := l.CheckRegexp(pattern); e != nil {
+			le := e.(*LimitError)
+			le.Pos = op.Pos
+			err = le
+			return
+		}
+	}
+	return
+}
@@ -14,17 +14,29 @@
 
 package cql
 
+import (
+	"encoding/json"
+
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+// Operation compares the Left operand, a context key, function call or
+// arithmetic expression, with the Right operand using the operator Type.
+// Operations with no Type are predicates, where the Left operand is a function
+// call returning a bool
 type Operation struct {
-	Left  *string `parser:" '(' '.' @Ident" json:"left"`
-	Type  string  `parser:"  ( @'!=' | @'==' | @'=~' | @'!~' )" json:"type"`
-	Right *Value  `parser:"  @@ ')'" json:"right"`
+	Pos lexer.Position `json:"-"`
+
+	Left  *Value   `json:"left"`
+	Type  Operator `json:"type,omitempty"`
+	Right *Value   `json:"right,omitempty"`
 }
 
 func (o *Operation) Render() (clone *Operation) {
 	clone = new(Operation)
+	clone.Pos = o.Pos
 	if o.Left != nil {
-		ident := *o.Left
-		clone.Left = &ident
+		clone.Left = o.Left.Render()
 	}
 	clone.Type = o.Type
 	if o.Right != nil {
@@ -32,3 +44,41 @@ func (o *Operation) Render() (clone *Operation) {
 	}
 	return
 }
+
+// Clone returns a deep copy of this Operation
+func (o *Operation) Clone() (clone *Operation) {
+	clone = new(Operation)
+	clone.Pos = o.Pos
+	if o.Left != nil {
+		clone.Left = o.Left.Clone()
+	}
+	clone.Type = o.Type
+	if o.Right != nil {
+		clone.Right = o.Right.Clone()
+	}
+	return
+}
+
+// UnmarshalJSON decodes the JSON form of an Operation, the left operand may
+// also be a plain context key string, as produced before function calls were
+// supported
+func (o *Operation) UnmarshalJSON(data []byte) (err error) {
+	type operation Operation
+	var decoded struct {
+		operation
+		Left json.RawMessage `json:"left"`
+	}
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		return
+	}
+	*o = Operation(decoded.operation)
+	if len(decoded.Left) > 0 && decoded.Left[0] == '"' {
+		var key string
+		if err = json.Unmarshal(decoded.Left, &key); err == nil {
+			o.Left = &Value{ContextKey: &key}
+		}
+	} else if len(decoded.Left) > 0 {
+		err = json.Unmarshal(decoded.Left, &o.Left)
+	}
+	return
+}
@@ -0,0 +1,32 @@
+// Copyright (c) 2024  The Go-CoreLibs Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cql
+
+import (
+	"strings"
+)
+
+// Operator is the type of Operation, multi-word operators such as IS NOT NULL
+// are captured as upper-cased words separated by single spaces
+type Operator string
+
+func (o *Operator) Capture(values []string) error {
+	words := strings.ToUpper(strings.Join(values, " "))
+	if *o != "" {
+		words = string(*o) + " " + words
+	}
+	*o = Operator(words)
+	return nil
+}
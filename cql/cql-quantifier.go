@@ -0,0 +1,54 @@
+// Copyright (c) 2024  The Go-CoreLibs Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cql
+
+import (
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+// Quantifier evaluates the Expression with each item of the list found at the
+// ContextKey, Type is ANY (at least one item matches) or ALL (every item
+// matches). Within the Expression, context keys refer to the keys of the item
+// and a Self value refers to the item itself
+//
+// Example:
+//
+//	ANY .Authors (.Name == 'Ann')
+//	ALL .Tags (. =~ m/^[a-z]+$/)
+type Quantifier struct {
+	Pos lexer.Position `json:"-"`
+
+	Type       string      `json:"type"`
+	ContextKey string      `json:"context-key"`
+	Expression *Expression `json:"expression"`
+}
+
+// Render returns a copy of this Quantifier with the expression rendered
+func (q *Quantifier) Render() (clone *Quantifier) {
+	clone = &Quantifier{Pos: q.Pos, Type: q.Type, ContextKey: q.ContextKey}
+	if q.Expression != nil {
+		clone.Expression = q.Expression.Render()
+	}
+	return
+}
+
+// Clone returns a deep copy of this Quantifier
+func (q *Quantifier) Clone() (clone *Quantifier) {
+	clone = &Quantifier{Pos: q.Pos, Type: q.Type, ContextKey: q.ContextKey}
+	if q.Expression != nil {
+		clone.Expression = q.Expression.Clone()
+	}
+	return
+}
@@ -0,0 +1,198 @@
+// Copyright (c) 2024  The Go-CoreLibs Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cql
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	gRegexpDelimiters = []byte{'/', '!', '@', '~'}
+	rxPlainSegment    = regexp.MustCompile(`^[\p{L}_][\p{L}\p{N}_]*$`)
+)
+
+// QuoteKey returns the given name as a single context key path segment,
+// double-quoting names which are not plain identifiers, for example:
+//
+//	QuoteKey("Title")        // Title
+//	QuoteKey("publish-date") // "publish-date"
+func QuoteKey(name string) (segment string) {
+	if rxPlainSegment.MatchString(name) {
+		return name
+	}
+	return strconv.Quote(name)
+}
+
+// QuoteString returns the given text as a CQL string literal, using single
+// quotes unless the text contains single quotes and no double quotes. Quotes,
+// backslashes and non-printable characters are escaped the same as Go strings
+func QuoteString(text string) (quoted string) {
+	quoted = strconv.Quote(text)
+	if strings.Contains(text, "'") && !strings.Contains(text, `"`) {
+		return
+	}
+	body := quoted[1 : len(quoted)-1]
+	body = strings.ReplaceAll(body, `\"`, `"`)
+	body = strings.ReplaceAll(body, `'`, `\'`)
+	quoted = "'" + body + "'"
+	return
+}
+
+// UnquoteString returns the text of the given CQL string literal, which is
+// either single or double quoted and may contain the Go string escape
+// sequences, including \uXXXX unicode escapes and escaped quote characters of
+// either style, so 'say \"hi\"' and "it\'s" are both valid
+func UnquoteString(s string) (out string, err error) {
+	if s == "" {
+		return
+	}
+	quote := s[0]
+	if quote != '\'' && quote != '"' {
+		err = fmt.Errorf("expected opening quote")
+		return
+	} else if last := len(s) - 1; last == 0 || s[last] != quote {
+		err = fmt.Errorf(`expected closing quote "%v"`, string(quote))
+		return
+	}
+
+	var buf strings.Builder
+	body := s[1 : len(s)-1]
+	for body != "" {
+		if len(body) > 1 && body[0] == '\\' && (body[1] == '\'' || body[1] == '"') {
+			// strconv only accepts escaping the enclosing quote character
+			buf.WriteByte(body[1])
+			body = body[2:]
+			continue
+		}
+		var value rune
+		var multibyte bool
+		if value, multibyte, body, err = strconv.UnquoteChar(body, quote); err != nil {
+			err = fmt.Errorf("invalid string literal escape sequence")
+			return
+		}
+		if value < 0x80 || !multibyte {
+			buf.WriteByte(byte(value))
+		} else {
+			buf.WriteRune(value)
+		}
+	}
+	out = buf.String()
+	return
+}
+
+// QuoteRegexp returns the given pattern as a CQL regexp literal, using the
+// first of the slash, bang, at or tilde delimiters not present in the pattern
+// and escaping the slashes otherwise
+func QuoteRegexp(pattern string) (quoted string) {
+	for _, delim := range gRegexpDelimiters {
+		if strings.IndexByte(pattern, delim) < 0 {
+			quoted = "m" + string(delim) + pattern + string(delim)
+			return
+		}
+	}
+	var buf strings.Builder
+	buf.WriteString("m/")
+	for idx := 0; idx < len(pattern); idx++ {
+		switch pattern[idx] {
+		case '\\':
+			buf.WriteByte('\\')
+			if idx+1 < len(pattern) {
+				idx += 1
+				buf.WriteByte(pattern[idx])
+			}
+		case '/':
+			buf.WriteString(`\/`)
+		default:
+			buf.WriteByte(pattern[idx])
+		}
+	}
+	buf.WriteString("/")
+	quoted = buf.String()
+	return
+}
+
+// UnquoteRegexp returns the pattern of the given CQL regexp literal, which is
+// an optional "m" followed by the pattern enclosed in one of the slash, bang,
+// at or tilde delimiters and any trailing flags (i, m and s). Escaped
+// delimiters within the pattern are unescaped and any flags are prefixed to
+// the pattern in the (?flags) form
+//
+// Examples:
+//
+//	UnquoteRegexp(`m/^a\/b$/`)   // `^a/b$`
+//	UnquoteRegexp(`m!^draft!i`)  // `(?i)^draft`
+func UnquoteRegexp(s string) (out string, err error) {
+	s = strings.TrimPrefix(s, "m")
+	if s == "" {
+		return
+	}
+
+	delim := s[0]
+	if strings.IndexByte(string(gRegexpDelimiters), delim) < 0 {
+		err = fmt.Errorf("expected opening character")
+		return
+	}
+	last := strings.LastIndexByte(s, delim)
+	if last == 0 {
+		err = fmt.Errorf(`expected closing character "%v"`, string(delim))
+		return
+	}
+	flags := s[last+1:]
+	for _, flag := range flags {
+		if !strings.ContainsRune("ims", flag) {
+			err = fmt.Errorf("unsupported regular expression flag %q", flag)
+			return
+		}
+	}
+
+	var buf strings.Builder
+	if flags != "" {
+		buf.WriteString("(?" + flags + ")")
+	}
+	body := s[1:last]
+	for idx := 0; idx < len(body); idx++ {
+		if body[idx] == '\\' && idx+1 < len(body) {
+			idx += 1
+			if body[idx] != delim {
+				buf.WriteByte('\\')
+			}
+		}
+		buf.WriteByte(body[idx])
+	}
+	out = buf.String()
+	return
+}
+
+// quoteValues converts the String and Regexp values of the given node, and all
+// of its children, from their rendered form to query syntax, the inverse of
+// Render
+func quoteValues(node Node) {
+	Inspect(node, func(n Node) bool {
+		if v, ok := n.(*Value); ok {
+			if v.String != nil {
+				quoted := QuoteString(*v.String)
+				v.String = &quoted
+			}
+			if v.Regexp != nil {
+				quoted := QuoteRegexp(*v.Regexp)
+				v.Regexp = &quoted
+			}
+		}
+		return true
+	})
+}
@@ -0,0 +1,219 @@
+// Copyright (c) 2024  The Go-CoreLibs Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cql
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// RewriteFunc is called by Statement.Rewrite for each node of a copy of the
+// statement and returns the node to use in its place, which is either the
+// node itself (possibly modified) or a new node of the same type
+type RewriteFunc func(node Node) (replacement Node, err error)
+
+// Rewrite returns a new Statement with each node replaced by the result of
+// calling fn, this Statement is not modified. The nodes are rewritten from the
+// bottom up, children before their parents, finishing with the new Statement
+// itself, and any replacement nodes are not rewritten again
+//
+// The new Statement is validated, checked against the Limits of this
+// Statement and has its ContextKeys recomputed
+//
+// The nodes given to fn always have String and Regexp values in query syntax
+// form, the same as the values returned by the builders such as StringValue.
+// When this Statement is rendered (see Render), the new Statement is rendered
+// as well
+//
+// Example, requiring a tenant for every query:
+//
+//	scoped, err := stmnt.Rewrite(func(node cql.Node) (cql.Node, error) {
+//	    if s, ok := node.(*cql.Statement); ok {
+//	        s.Expression = cql.And(cql.Eq("Tenant", cql.StringValue(tenant)), s.Expression)
+//	    }
+//	    return node, nil
+//	})
+func (s *Statement) Rewrite(fn RewriteFunc) (out *Statement, err error) {
+	source := s.Clone()
+	if source.rendered {
+		if source.Expression != nil {
+			quoteValues(source.Expression)
+		}
+		source.rendered = false
+	}
+	var node Node
+	if node, err = rewriteNode(source, fn); err != nil {
+		return
+	}
+	rewritten := node.(*Statement)
+	if err = rewritten.Validate(); err != nil {
+		return
+	}
+	if pErr := rewritten.limits.checkExpression(rewritten.Expression, true); pErr != nil {
+		err = pErr
+		return
+	}
+	rewritten.ContextKeys = extractContextKeys(rewritten.Expression)
+	if out = rewritten; s.rendered {
+		out = rewritten.Render()
+	}
+	return
+}
+
+func rewriteNode(node Node, fn RewriteFunc) (replacement Node, err error) {
+	var child Node
+	switch n := node.(type) {
+	case *Statement:
+		if n.Expression != nil {
+			if child, err = rewriteNode(n.Expression, fn); err != nil {
+				return
+			}
+			n.Expression = child.(*Expression)
+		}
+	case *Expression:
+		if n.Condition != nil {
+			if child, err = rewriteNode(n.Condition, fn); err != nil {
+				return
+			}
+			n.Condition = child.(*Condition)
+		}
+		if n.Operation != nil {
+			if child, err = rewriteNode(n.Operation, fn); err != nil {
+				return
+			}
+			n.Operation = child.(*Operation)
+		}
+		if n.Quantifier != nil {
+			if child, err = rewriteNode(n.Quantifier, fn); err != nil {
+				return
+			}
+			n.Quantifier = child.(*Quantifier)
+		}
+	case *Condition:
+		if n.Left != nil {
+			if child, err = rewriteNode(n.Left, fn); err != nil {
+				return
+			}
+			n.Left = child.(*Expression)
+		}
+		if n.Right != nil {
+			if child, err = rewriteNode(n.Right, fn); err != nil {
+				return
+			}
+			n.Right = child.(*Expression)
+		}
+	case *Operation:
+		if n.Left != nil {
+			if child, err = rewriteNode(n.Left, fn); err != nil {
+				return
+			}
+			n.Left = child.(*Value)
+		}
+		if n.Right != nil {
+			if child, err = rewriteNode(n.Right, fn); err != nil {
+				return
+			}
+			n.Right = child.(*Value)
+		}
+	case *Quantifier:
+		if n.Expression != nil {
+			if child, err = rewriteNode(n.Expression, fn); err != nil {
+				return
+			}
+			n.Expression = child.(*Expression)
+		}
+	case *Value:
+		for idx, item := range n.List {
+			if item != nil {
+				if child, err = rewriteNode(item, fn); err != nil {
+					return
+				}
+				n.List[idx] = child.(*Value)
+			}
+		}
+		if n.Call != nil {
+			for idx, arg := range n.Call.Args {
+				if arg != nil {
+					if child, err = rewriteNode(arg, fn); err != nil {
+						return
+					}
+					n.Call.Args[idx] = child.(*Value)
+				}
+			}
+		}
+		if n.Arithmetic != nil {
+			if n.Arithmetic.Left != nil {
+				if child, err = rewriteNode(n.Arithmetic.Left, fn); err != nil {
+					return
+				}
+				n.Arithmetic.Left = child.(*Value)
+			}
+			if n.Arithmetic.Right != nil {
+				if child, err = rewriteNode(n.Arithmetic.Right, fn); err != nil {
+					return
+				}
+				n.Arithmetic.Right = child.(*Value)
+			}
+		}
+	}
+
+	if replacement, err = fn(node); err != nil {
+		return
+	} else if replacement == nil || reflect.ValueOf(replacement).IsNil() {
+		err = fmt.Errorf("rewrite replaced %T with nil", node)
+	} else if reflect.TypeOf(replacement) != reflect.TypeOf(node) {
+		err = fmt.Errorf("rewrite replaced %T with %T", node, replacement)
+	}
+	return
+}
+
+// RenameKey returns a RewriteFunc which renames all references to the context
+// key from, including deep keys within it, to the context key to. The keys
+// within ANY and ALL expressions refer to the list items and are not renamed
+//
+// Example:
+//
+//	stmnt, err = stmnt.Rewrite(cql.RenameKey("Date", "Published"))
+//	// .Date == .Updated       => .Published == .Updated
+//	// .Date.Year IN (.Years)  => .Published.Year IN (.Years)
+func RenameKey(from, to string) RewriteFunc {
+	rename := func(key *string) {
+		if key == nil {
+			return
+		} else if *key == from {
+			*key = to
+		} else if rest := strings.TrimPrefix(*key, from); rest != *key && (rest[0] == '.' || rest[0] == '[') {
+			*key = to + rest
+		}
+	}
+	return func(node Node) (Node, error) {
+		// the whole statement is renamed at once, as the scope of the keys
+		// is not known when rewriting individual nodes
+		if s, ok := node.(*Statement); ok {
+			Inspect(s, func(node Node) bool {
+				switch n := node.(type) {
+				case *Quantifier:
+					rename(&n.ContextKey)
+					return false
+				case *Value:
+					rename(n.ContextKey)
+				}
+				return true
+			})
+		}
+		return node, nil
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2024  The Go-CoreLibs Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cql
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type traceVisitor struct {
+	trace *[]string
+}
+
+func (v traceVisitor) Visit(node Node) Visitor {
+	switch n := node.(type) {
+	case nil:
+		*v.trace = append(*v.trace, "end")
+	case *Condition:
+		*v.trace = append(*v.trace, n.Type)
+	case *Operation:
+		*v.trace = append(*v.trace, string(n.Type))
+	case *Value:
+		*v.trace = append(*v.trace, n.format(true))
+	default:
+		*v.trace = append(*v.trace, fmt.Sprintf("%T", n))
+	}
+	return v
+}
+
+func TestRewrite(t *testing.T) {
+	Convey("Walk", t, func() {
+		stmnt, err := Compile(`.A == 1 AND NOT .B IN ('x', .C)`)
+		So(err, ShouldBeNil)
+		var trace []string
+		Walk(traceVisitor{trace: &trace}, stmnt)
+		So(trace, ShouldEqual, []string{
+			"*cql.Statement", "*cql.Expression", "AND",
+			"*cql.Expression", "==", ".A", "end", "1", "end", "end", "end",
+			"*cql.Expression", "NOT",
+			"*cql.Expression", "IN", ".B", "end", "('x', .C)", "'x'", "end", ".C", "end", "end", "end", "end",
+			"end", "end",
+			"end", "end", "end",
+		})
+	})
+
+	Convey("Inspect", t, func() {
+		stmnt, err := Compile(`.A == 1 OR (.Secret == 'x' AND .B == 2)`)
+		So(err, ShouldBeNil)
+		var keys []string
+		Inspect(stmnt, func(node Node) bool {
+			if op, ok := node.(*Operation); ok {
+				keys = append(keys, *op.Left.ContextKey)
+				return *op.Left.ContextKey != "Secret"
+			}
+			return true
+		})
+		So(keys, ShouldEqual, []string{"A", "Secret", "B"})
+
+		var conditions int
+		Inspect(stmnt.Expression, func(node Node) bool {
+			if _, ok := node.(*Condition); ok {
+				conditions += 1
+				return false
+			}
+			return true
+		})
+		So(conditions, ShouldEqual, 1)
+	})
+
+	Convey("Rewrite", t, func() {
+		stmnt, err := Compile(`.Date > .Updated AND .Date.Year IN (2023, .Dates[0]) AND .DateOther == 1`)
+		So(err, ShouldBeNil)
+		renamed, e := stmnt.Rewrite(RenameKey("Date", "Published"))
+		So(e, ShouldBeNil)
+		So(renamed.String(), ShouldEqual, `(((.Published > .Updated) AND (.Published.Year IN (2023, .Dates[0]))) AND (.DateOther == 1))`)
+		So(renamed.ContextKeys, ShouldEqual, []string{"DateOther", "Dates[0]", "Published", "Published.Year", "Updated"})
+		So(stmnt.String(), ShouldEqual, `(((.Date > .Updated) AND (.Date.Year IN (2023, .Dates[0]))) AND (.DateOther == 1))`)
+
+		scoped, e := stmnt.Rewrite(func(node Node) (Node, error) {
+			if s, ok := node.(*Statement); ok {
+				s.Expression = And(Eq("Tenant", StringValue("acme")), s.Expression)
+			}
+			return node, nil
+		})
+		So(e, ShouldBeNil)
+		So(scoped.String(), ShouldStartWith, `((.Tenant == 'acme') AND (((.Date > .Updated)`)
+		So(scoped.ContextKeys, ShouldContain, "Tenant")
+
+		errForbidden := errors.New("forbidden")
+		_, e = stmnt.Rewrite(func(node Node) (Node, error) {
+			if v, ok := node.(*Value); ok && v.ContextKey != nil && *v.ContextKey == "Updated" {
+				return nil, errForbidden
+			}
+			return node, nil
+		})
+		So(e, ShouldEqual, errForbidden)
+
+		_, e = stmnt.Rewrite(func(node Node) (Node, error) {
+			if _, ok := node.(*Operation); ok {
+				return IntValue(1), nil
+			}
+			return node, nil
+		})
+		So(e, ShouldNotBeNil)
+
+		_, e = stmnt.Rewrite(func(node Node) (Node, error) {
+			if v, ok := node.(*Value); ok && v.Int != nil {
+				return &Value{}, nil
+			}
+			return node, nil
+		})
+		So(e, ShouldNotBeNil)
+
+		limited, _ := CompileWithLimits(`.A == 1`, Limits{MaxOperations: 1})
+		_, e = limited.Rewrite(func(node Node) (Node, error) {
+			if expr, ok := node.(*Expression); ok && expr.Operation != nil {
+				return Or(expr, Eq("B", IntValue(2))), nil
+			}
+			return node, nil
+		})
+		var limitErr *LimitError
+		So(errors.As(e, &limitErr), ShouldBeTrue)
+	})
+}
@@ -21,47 +21,71 @@ import (
 )
 
 type Statement struct {
-	Expression  *Expression `parser:"@@" json:"expressions,omitempty"`
-	ContextKeys []string    `parser:"" json:"context-keys,omitempty"`
-	rendered    bool        `parser:""`
+	Expression  *Expression `json:"expressions,omitempty"`
+	ContextKeys []string    `json:"context-keys,omitempty"`
+	rendered    bool
+	limits      Limits
 }
 
+// Limits returns the resource limits this Statement was compiled with
+func (s *Statement) Limits() Limits {
+	return s.limits
+}
+
+// Render returns a copy of this Statement with the String and Regexp values
+// unquoted, ready for evaluation, rendered statements are returned as a Clone
 func (s *Statement) Render() (out *Statement) {
+	if s.rendered {
+		return s.Clone()
+	}
 	out = new(Statement)
 	if s.Expression != nil {
 		out.Expression = s.Expression.Render()
 	}
 	out.ContextKeys = append(out.ContextKeys, s.ContextKeys...)
 	out.rendered = true
+	out.limits = s.limits
 	return
 }
 
-func (s *Statement) String() (query string) {
-	if s.rendered {
-		return
+// Clone returns a deep copy of this Statement
+func (s *Statement) Clone() (out *Statement) {
+	out = &Statement{rendered: s.rendered, limits: s.limits}
+	if s.Expression != nil {
+		out.Expression = s.Expression.Clone()
 	}
+	out.ContextKeys = append(out.ContextKeys, s.ContextKeys...)
+	return
+}
+
+// String returns the query syntax for this Statement, with each operation
+// and condition parenthesized, see Format for the canonical form
+func (s *Statement) String() (query string) {
+	quoted := !s.rendered
 	var compile func(expr *Expression)
 	compile = func(expr *Expression) {
 		switch {
 		case expr.Operation != nil:
-			var right string
+			left := expr.Operation.Left.format(quoted)
 			switch {
-			case expr.Operation.Right.ContextKey != nil:
-				right = "." + *expr.Operation.Right.ContextKey
-			case expr.Operation.Right.String != nil:
-				right = *expr.Operation.Right.String
-			case expr.Operation.Right.Regexp != nil:
-				right = "m" + *expr.Operation.Right.Regexp
-			case expr.Operation.Right.Int != nil:
-				right = fmt.Sprintf("%v", *expr.Operation.Right.Int)
-			case expr.Operation.Right.Float != nil:
-				right = fmt.Sprintf("%v", *expr.Operation.Right.Float)
-			case expr.Operation.Right.Bool != nil:
-				right = fmt.Sprintf("%v", *expr.Operation.Right.Bool)
-			case expr.Operation.Right.Nil != nil:
-				right = "nil"
+			case expr.Operation.Type == "":
+				query += fmt.Sprintf("(%s)", left)
+			case expr.Operation.Right == nil:
+				query += fmt.Sprintf("(%s %s)", left, expr.Operation.Type)
+			default:
+				right := expr.Operation.Right.format(quoted)
+				query += fmt.Sprintf("(%s %s %s)", left, expr.Operation.Type, right)
 			}
-			query += fmt.Sprintf("(.%s %s %s)", *expr.Operation.Left, expr.Operation.Type, right)
+
+		case expr.Quantifier != nil:
+			query += "(" + expr.Quantifier.Type + " ." + expr.Quantifier.ContextKey + " "
+			compile(expr.Quantifier.Expression)
+			query += ")"
+
+		case expr.Condition != nil && expr.Condition.Left == nil:
+			query += "(" + strings.ToUpper(expr.Condition.Type) + " "
+			compile(expr.Condition.Right)
+			query += ")"
 
 		case expr.Condition != nil:
 			query += "("
@@ -71,7 +95,9 @@ func (s *Statement) String() (query string) {
 			query += ")"
 		}
 	}
-	compile(s.Expression)
+	if s.Expression != nil {
+		compile(s.Expression)
+	}
 	return
 }
 
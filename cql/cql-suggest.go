@@ -0,0 +1,161 @@
+// Copyright (c) 2024  The Go-CoreLibs Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cql
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/maruel/natural"
+)
+
+// maxSuggestions is the maximum number of candidates of a Suggestion
+const maxSuggestions = 3
+
+// Suggestion is a "did you mean" suggestion for a context key referenced by a
+// Statement which is not known
+type Suggestion struct {
+	// Key is the unknown context key
+	Key string
+	// Pos is the position of the first operation referencing the Key
+	Pos lexer.Position
+	// Candidates are the closest known keys, closest first, and may be empty
+	Candidates []string
+}
+
+// String returns a human-readable description of this Suggestion
+func (s *Suggestion) String() (text string) {
+	text = "unknown context key ." + s.Key
+	if len(s.Candidates) > 0 {
+		text += ", did you mean ." + strings.Join(s.Candidates, " or .") + "?"
+	}
+	return
+}
+
+// Suggest returns a Suggestion for each of the ContextKeys which are not
+// known, in the order of the ContextKeys. Each key is checked with the found
+// function, or if found is nil, by being present in the list of known keys.
+// The candidates are the known keys within a small edit distance of the
+// unknown key, ignoring case
+func (s *Statement) Suggest(known []string, found func(key string) bool) (suggestions []*Suggestion) {
+	if found == nil {
+		lookup := make(map[string]bool, len(known))
+		for _, key := range known {
+			lookup[key] = true
+		}
+		found = func(key string) bool {
+			return lookup[key]
+		}
+	}
+
+	positions := make(map[string]lexer.Position)
+	Inspect(s, func(node Node) bool {
+		if quant, ok := node.(*Quantifier); ok {
+			// the keys within the quantified expression refer to the items
+			if _, present := positions[quant.ContextKey]; !present {
+				positions[quant.ContextKey] = quant.Pos
+			}
+			return false
+		}
+		if op, ok := node.(*Operation); ok {
+			var keys []string
+			if op.Left != nil {
+				keys = append(keys, op.Left.contextKeys()...)
+			}
+			if op.Right != nil {
+				keys = append(keys, op.Right.contextKeys()...)
+			}
+			for _, key := range keys {
+				if _, present := positions[key]; !present {
+					positions[key] = op.Pos
+				}
+			}
+		}
+		return true
+	})
+
+	for _, key := range s.ContextKeys {
+		if !found(key) {
+			suggestions = append(suggestions, &Suggestion{
+				Key:        key,
+				Pos:        positions[key],
+				Candidates: closestKeys(key, known),
+			})
+		}
+	}
+	return
+}
+
+// closestKeys returns the known keys within the edit distance allowed for the
+// given key, closest first
+func closestKeys(key string, known []string) (closest []string) {
+	limit := len([]rune(key)) / 3
+	if limit < 1 {
+		limit = 1
+	}
+
+	distances := make(map[string]int)
+	for _, candidate := range known {
+		if candidate == key {
+			continue
+		} else if _, present := distances[candidate]; present {
+			continue
+		}
+		if d := editDistance(strings.ToLower(key), strings.ToLower(candidate)); d <= limit {
+			distances[candidate] = d
+			closest = append(closest, candidate)
+		}
+	}
+
+	sort.SliceStable(closest, func(i, j int) bool {
+		if di, dj := distances[closest[i]], distances[closest[j]]; di != dj {
+			return di < dj
+		}
+		return natural.Less(closest[i], closest[j])
+	})
+	if len(closest) > maxSuggestions {
+		closest = closest[:maxSuggestions]
+	}
+	return
+}
+
+// editDistance returns the optimal string alignment distance between a and b,
+// which is the Levenshtein distance with adjacent transpositions counted as a
+// single edit
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	rows := make([][]int, len(ra)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(rb)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			rows[i][j] = min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				rows[i][j] = min(rows[i][j], rows[i-2][j-2]+1)
+			}
+		}
+	}
+	return rows[len(ra)][len(rb)]
+}
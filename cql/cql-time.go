@@ -0,0 +1,120 @@
+// Copyright (c) 2024  The Go-CoreLibs Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cql
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const gDateOnly = "2006-01-02"
+
+var (
+	rxDurationParts = regexp.MustCompile(`(\d+(?:\.\d+)?)(ns|us|µs|ms|s|m|h|d)`)
+)
+
+// ParseTime parses a date or time literal, either a date-only value such as
+// 2024-01-02, which is midnight UTC, or an RFC 3339 timestamp such as
+// 2024-01-02T15:04:05Z or 2024-01-02T15:04:05.5+01:00
+func ParseTime(text string) (t time.Time, err error) {
+	if len(text) == len(gDateOnly) {
+		t, err = time.ParseInLocation(gDateOnly, text, time.UTC)
+	} else {
+		t, err = time.Parse(time.RFC3339Nano, text)
+	}
+	if err != nil {
+		err = fmt.Errorf("invalid time literal %q", text)
+	}
+	return
+}
+
+// ParseDuration parses a duration literal, which is the same as the input to
+// time.ParseDuration with the addition of the d (24h) unit, such as 7d, 36h
+// or -1d12h30m
+func ParseDuration(text string) (d time.Duration, err error) {
+	rest := text
+	var negative bool
+	if strings.HasPrefix(rest, "-") {
+		negative, rest = true, rest[1:]
+	} else {
+		rest = strings.TrimPrefix(rest, "+")
+	}
+	if rest == "" {
+		err = fmt.Errorf("invalid duration literal %q", text)
+		return
+	}
+	for _, part := range rxDurationParts.FindAllStringSubmatch(rest, -1) {
+		rest = strings.TrimPrefix(rest, part[0])
+		var value time.Duration
+		if part[2] == "d" {
+			var days float64
+			if days, err = strconv.ParseFloat(part[1], 64); err == nil {
+				value = time.Duration(days * float64(24*time.Hour))
+			}
+		} else {
+			value, err = time.ParseDuration(part[0])
+		}
+		if err != nil {
+			break
+		}
+		d += value
+	}
+	if err != nil || rest != "" {
+		d, err = 0, fmt.Errorf("invalid duration literal %q", text)
+	} else if negative {
+		d = -d
+	}
+	return
+}
+
+// formatTime returns the literal for the given time, date-only when the time
+// is midnight UTC
+func formatTime(t time.Time) (text string) {
+	if t.Location() == time.UTC && t.Equal(t.Truncate(24*time.Hour)) {
+		text = t.Format(gDateOnly)
+	} else {
+		text = t.Format(time.RFC3339Nano)
+	}
+	return
+}
+
+// formatDuration returns the literal for the given duration, using the d unit
+// for whole days and omitting any zero units
+func formatDuration(d time.Duration) (text string) {
+	if d < 0 {
+		return "-" + formatDuration(-d)
+	} else if d == 0 {
+		return "0s"
+	}
+	if days := d / (24 * time.Hour); days > 0 {
+		text = strconv.FormatInt(int64(days), 10) + "d"
+		if d -= days * 24 * time.Hour; d == 0 {
+			return
+		}
+	}
+	rest := d.String()
+	for _, zero := range []string{"0s", "0m"} {
+		if trimmed := strings.TrimSuffix(rest, zero); trimmed != rest && trimmed != "" && !strings.HasSuffix(trimmed, ".") {
+			if last := trimmed[len(trimmed)-1]; last < '0' || last > '9' {
+				rest = trimmed
+			}
+		}
+	}
+	text += rest
+	return
+}
@@ -0,0 +1,243 @@
+// Copyright (c) 2024  The Go-CoreLibs Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cql
+
+import (
+	"errors"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/alecthomas/participle/v2"
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+var (
+	rxValidIdent  = regexp.MustCompile(`^` + gIdent + `$`)
+	rxValidHolder = regexp.MustCompile(`^(?:` + gHolder + `)$`)
+	rxValidString = regexp.MustCompile(`^(?:` + gString + `)$`)
+	rxValidRegexp = regexp.MustCompile(`^(?:` + gRegexp + `)$`)
+)
+
+// Validate checks that this Statement is well-formed, which is always the case
+// for statements returned by Compile and needs checking for statements that
+// were constructed directly, such as with the query builder functions
+func (s *Statement) Validate() (err error) {
+	if s.Expression == nil {
+		err = errors.New("missing expression")
+		return
+	}
+	if pErr := validateExpression(s.Expression, !s.rendered); pErr != nil {
+		err = errors.New(pErr.Message())
+	}
+	return
+}
+
+// validateExpression checks the structure of the given expression, quoted is
+// true when String and Regexp values are expected to be in their query syntax
+// form (as opposed to Render form)
+func validateExpression(expr *Expression, quoted bool) (err participle.Error) {
+	switch {
+
+	case expr == nil:
+		err = participle.Errorf(lexer.Position{}, "missing expression")
+
+	case expr.Condition != nil && expr.Operation != nil,
+		expr.Quantifier != nil && (expr.Condition != nil || expr.Operation != nil):
+		err = participle.Errorf(lexer.Position{}, "expression has more than one of a condition, operation or quantifier")
+
+	case expr.Operation != nil:
+		err = validateOperation(expr.Operation, quoted)
+
+	case expr.Quantifier != nil:
+		quant := expr.Quantifier
+		switch {
+		case quant.Type != "ANY" && quant.Type != "ALL":
+			err = participle.Errorf(quant.Pos, "unknown quantifier type %q", quant.Type)
+		case !rxValidIdent.MatchString(quant.ContextKey):
+			err = participle.Errorf(quant.Pos, "invalid context key")
+		default:
+			err = validateExpression(quant.Expression, quoted)
+		}
+
+	case expr.Condition != nil:
+		cond := expr.Condition
+		switch strings.ToUpper(cond.Type) {
+		case "NOT":
+			if cond.Left != nil {
+				err = participle.Errorf(lexer.Position{}, "NOT conditions only have a right expression")
+				return
+			}
+		case "AND", "OR":
+			if err = validateExpression(cond.Left, quoted); err != nil {
+				return
+			}
+		default:
+			err = participle.Errorf(lexer.Position{}, "unknown condition type %q", cond.Type)
+			return
+		}
+		err = validateExpression(cond.Right, quoted)
+
+	default:
+		err = participle.Errorf(lexer.Position{}, "expression has no condition, operation or quantifier")
+
+	}
+	return
+}
+
+func validateOperation(op *Operation, quoted bool) (err participle.Error) {
+	if op.Left == nil || (op.Left.ContextKey == nil && op.Left.Self == nil && op.Left.Call == nil && op.Left.Arithmetic == nil) {
+		err = participle.Errorf(op.Pos, "expected a context key, function call or arithmetic expression")
+		return
+	} else if e := op.Left.validate(quoted); e != nil {
+		err = participle.Errorf(errorPosition(e, op.Pos), "%v", e.Error())
+		return
+	}
+
+	switch op.Type {
+
+	case "":
+		if op.Left.Call == nil {
+			err = participle.Errorf(op.Pos, "expected an operator")
+		} else if op.Right != nil {
+			err = participle.Errorf(op.Pos, "predicates do not accept a value")
+		} else if t := valueType(op.Left); !BoolType.accepts(t) {
+			err = participle.Errorf(op.Pos, "%v returns %v, expected bool", op.Left.Call.Name, t)
+		}
+		return
+
+	case "IS NULL", "IS NOT NULL":
+		if op.Right != nil {
+			err = participle.Errorf(op.Pos, "%v does not accept a value", op.Type)
+		}
+		return
+
+	case "IN":
+		if op.Right == nil || (op.Right.List == nil && op.Right.ContextKey == nil && op.Right.Placeholder == nil) {
+			err = participle.Errorf(valuePosition(op.Right, op.Pos), "IN expects a list, context key or placeholder")
+			return
+		} else if op.Right.List != nil && len(op.Right.List) == 0 {
+			err = participle.Errorf(valuePosition(op.Right, op.Pos), "IN lists must not be empty")
+			return
+		}
+		for _, item := range op.Right.List {
+			if item != nil && item.List != nil {
+				err = participle.Errorf(valuePosition(item, op.Pos), "IN lists cannot be nested")
+				return
+			}
+		}
+
+	case "==", "!=", "==*", "!=*", "=~", "!~", "<", "<=", ">", ">=":
+		if op.Right == nil {
+			err = participle.Errorf(op.Pos, "%v expects a value", op.Type)
+			return
+		} else if op.Right.List != nil {
+			err = participle.Errorf(valuePosition(op.Right, op.Pos), "%v does not accept a list", op.Type)
+			return
+		}
+
+	default:
+		err = participle.Errorf(op.Pos, "unknown operator %q", op.Type)
+		return
+
+	}
+
+	if e := op.Right.validate(quoted); e != nil {
+		err = participle.Errorf(errorPosition(e, op.Pos), "%v", e.Error())
+	}
+	return
+}
+
+// valuePosition returns the position of the given value, or the position
+// given when the value has none, such as values made with the builders
+func valuePosition(v *Value, pos lexer.Position) lexer.Position {
+	if v != nil && v.Pos.Line > 0 {
+		return v.Pos
+	}
+	return pos
+}
+
+// validate checks that exactly one kind of value is present and that it is
+// well-formed, errors are reported at the position of this value unless the
+// error is of one of its children
+func (v *Value) validate(quoted bool) (err error) {
+	defer func() {
+		if err != nil {
+			err = atPosition(v.Pos, err)
+		}
+	}()
+	var kinds int
+	for _, present := range []bool{
+		v.ContextKey != nil, v.Regexp != nil, v.String != nil, v.Int != nil,
+		v.Float != nil, v.Bool != nil, v.Nil != nil, v.List != nil,
+		v.Placeholder != nil, v.Call != nil, v.Time != nil, v.Duration != nil,
+		v.Arithmetic != nil, v.Self != nil,
+	} {
+		if present {
+			kinds += 1
+		}
+	}
+	if kinds != 1 {
+		err = errors.New("values must have exactly one kind")
+		return
+	}
+
+	switch {
+	case v.ContextKey != nil:
+		if !rxValidIdent.MatchString(*v.ContextKey) {
+			err = errors.New("invalid context key")
+		}
+	case v.String != nil && quoted:
+		if !rxValidString.MatchString(*v.String) {
+			err = errors.New("invalid string literal")
+		} else if _, e := UnquoteString(*v.String); e != nil {
+			err = e
+		}
+	case v.Regexp != nil && quoted:
+		if !rxValidRegexp.MatchString(*v.Regexp) {
+			err = errors.New("invalid regular expression literal")
+		} else if _, e := UnquoteRegexp(*v.Regexp); e != nil {
+			err = e
+		}
+	case v.Float != nil:
+		if math.IsNaN(*v.Float) || math.IsInf(*v.Float, 0) {
+			err = errors.New("float literals must be finite numbers")
+		}
+	case v.Placeholder != nil:
+		if !rxValidHolder.MatchString(*v.Placeholder) {
+			err = errors.New("invalid placeholder")
+		}
+	case v.Call != nil:
+		err = v.Call.validate(quoted)
+	case v.Time != nil:
+		_, err = ParseTime(*v.Time)
+	case v.Duration != nil:
+		_, err = ParseDuration(*v.Duration)
+	case v.Arithmetic != nil:
+		err = v.Arithmetic.validate(quoted)
+	case v.List != nil:
+		for _, item := range v.List {
+			if item == nil {
+				err = errors.New("missing list item")
+			} else {
+				err = item.validate(quoted)
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+	return
+}
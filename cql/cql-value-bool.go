@@ -14,9 +14,13 @@
 
 package cql
 
+import (
+	"strings"
+)
+
 type Boolean bool
 
 func (b *Boolean) Capture(values []string) error {
-	*b = values[0] == "true"
+	*b = Boolean(strings.EqualFold(values[0], "true"))
 	return nil
 }
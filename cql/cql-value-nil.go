@@ -14,9 +14,13 @@
 
 package cql
 
+import (
+	"strings"
+)
+
 type Nil bool
 
 func (n *Nil) Capture(values []string) error {
-	*n = values[0] == "nil"
+	*n = Nil(strings.EqualFold(values[0], "nil"))
 	return nil
 }
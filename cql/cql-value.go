@@ -14,20 +14,36 @@
 
 package cql
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/participle/v2/lexer"
+)
 
 type Value struct {
-	ContextKey *string  `parser:"  ( '.' @Ident )" json:"context-key,omitempty"`
-	Regexp     *string  `parser:"| ( 'm' @Regexp )" json:"regexp,omitempty"`
-	String     *string  `parser:"| ( @String )" json:"string,omitempty"`
-	Int        *int     `parser:"| ( @Int )" json:"int,omitempty"`
-	Float      *float64 `parser:"| ( @Float )" json:"float,omitempty"`
-	Bool       *Boolean `parser:"| @( 'true' | 'false' )" json:"bool,omitempty"`
-	Nil        *Nil     `parser:"| @( 'nil' )" json:"nil,omitempty"`
+	Pos lexer.Position `parser:"" json:"-"`
+
+	ContextKey  *string  `parser:"  ( @Key )" json:"context-key,omitempty"`
+	Regexp      *string  `parser:"| ( @Regexp )" json:"regexp,omitempty"`
+	String      *string  `parser:"| ( @String )" json:"string,omitempty"`
+	Int         *int     `parser:"| ( @( ( '-' | '+' )? Int ) )" json:"int,omitempty"`
+	Float       *float64 `parser:"| ( @( ( '-' | '+' )? Float ) )" json:"float,omitempty"`
+	Bool        *Boolean `parser:"| @( 'true' | 'false' )" json:"bool,omitempty"`
+	Nil         *Nil     `parser:"| @( 'nil' )" json:"nil,omitempty"`
+	List        []*Value `parser:"| ( '(' @@ ( ',' @@ )* ')' )" json:"list,omitempty"`
+	Placeholder *string  `parser:"| ( @Placeholder )" json:"placeholder,omitempty"`
+	Call        *Call    `parser:"| @@" json:"call,omitempty"`
+	Time        *string  `parser:"| ( @DateTime )" json:"time,omitempty"`
+	Duration    *string  `parser:"| ( @( ( '-' | '+' )? Duration ) )" json:"duration,omitempty"`
+	Self        *bool    `parser:"| @'.'" json:"self,omitempty"`
+	// Arithmetic is folded from the operand grammar and not parsed directly
+	Arithmetic *Arithmetic `parser:"" json:"arithmetic,omitempty"`
 }
 
 func (v *Value) Render() (clone *Value) {
-	clone = new(Value)
+	clone = &Value{Pos: v.Pos}
 	if v.ContextKey != nil {
 		key := *v.ContextKey
 		clone.ContextKey = &key
@@ -58,39 +74,157 @@ func (v *Value) Render() (clone *Value) {
 		nl := *v.Nil
 		clone.Nil = &nl
 	}
+	for _, item := range v.List {
+		clone.List = append(clone.List, item.Render())
+	}
+	if v.Placeholder != nil {
+		name := *v.Placeholder
+		clone.Placeholder = &name
+	}
+	if v.Call != nil {
+		clone.Call = v.Call.Render()
+	}
+	if v.Time != nil {
+		text := *v.Time
+		clone.Time = &text
+	}
+	if v.Duration != nil {
+		text := *v.Duration
+		clone.Duration = &text
+	}
+	if v.Self != nil {
+		self := *v.Self
+		clone.Self = &self
+	}
+	if v.Arithmetic != nil {
+		clone.Arithmetic = v.Arithmetic.Render()
+	}
+	return
+}
+
+// Clone returns a deep copy of this Value
+func (v *Value) Clone() (clone *Value) {
+	clone = &Value{Pos: v.Pos}
+	if v.ContextKey != nil {
+		key := *v.ContextKey
+		clone.ContextKey = &key
+	}
+	if v.Regexp != nil {
+		pattern := *v.Regexp
+		clone.Regexp = &pattern
+	}
+	if v.String != nil {
+		text := *v.String
+		clone.String = &text
+	}
+	if v.Int != nil {
+		num := *v.Int
+		clone.Int = &num
+	}
+	if v.Float != nil {
+		num := *v.Float
+		clone.Float = &num
+	}
+	if v.Bool != nil {
+		bl := *v.Bool
+		clone.Bool = &bl
+	}
+	if v.Nil != nil {
+		nl := *v.Nil
+		clone.Nil = &nl
+	}
+	for _, item := range v.List {
+		clone.List = append(clone.List, item.Clone())
+	}
+	if v.Placeholder != nil {
+		name := *v.Placeholder
+		clone.Placeholder = &name
+	}
+	if v.Call != nil {
+		clone.Call = v.Call.Clone()
+	}
+	if v.Time != nil {
+		text := *v.Time
+		clone.Time = &text
+	}
+	if v.Duration != nil {
+		text := *v.Duration
+		clone.Duration = &text
+	}
+	if v.Self != nil {
+		self := *v.Self
+		clone.Self = &self
+	}
+	if v.Arithmetic != nil {
+		clone.Arithmetic = v.Arithmetic.Clone()
+	}
 	return
 }
 
-func UnquoteString(s string) (out string, err error) {
-	if s != "" {
-		last := len(s) - 1
-		for _, quote := range []uint8{'\'', '"'} {
-			if s[0] == quote {
-				if s[last] == quote {
-					out = s[1:last]
-				} else {
-					err = fmt.Errorf(`expected closing quote "%v"`, string(quote))
-				}
-				return
-			}
+// contextKeys returns all the context keys referenced by this Value
+func (v *Value) contextKeys() (keys []string) {
+	if v.ContextKey != nil {
+		keys = append(keys, *v.ContextKey)
+	}
+	for _, item := range v.List {
+		keys = append(keys, item.contextKeys()...)
+	}
+	if v.Call != nil {
+		for _, arg := range v.Call.Args {
+			keys = append(keys, arg.contextKeys()...)
 		}
 	}
+	if v.Arithmetic != nil {
+		keys = append(keys, v.Arithmetic.Left.contextKeys()...)
+		keys = append(keys, v.Arithmetic.Right.contextKeys()...)
+	}
 	return
 }
 
-func UnquoteRegexp(s string) (out string, err error) {
-	if s != "" {
-		last := len(s) - 1
-		for _, quote := range []uint8{'/', '!', '@', '~'} {
-			if s[0] == quote {
-				if s[last] == quote {
-					out = s[1:last]
-				} else {
-					err = fmt.Errorf(`expected closing character "%v"`, string(quote))
-				}
-				return
-			}
+// format returns the query syntax for this Value, quoted is false when the
+// String and Regexp values are in their Render form and need quoting
+func (v *Value) format(quoted bool) (text string) {
+	switch {
+	case v.ContextKey != nil:
+		text = "." + *v.ContextKey
+	case v.String != nil && quoted:
+		text = *v.String
+	case v.String != nil:
+		text = QuoteString(*v.String)
+	case v.Regexp != nil && quoted:
+		text = *v.Regexp
+	case v.Regexp != nil:
+		text = QuoteRegexp(*v.Regexp)
+	case v.Int != nil:
+		text = fmt.Sprintf("%v", *v.Int)
+	case v.Float != nil:
+		// always include a decimal point or exponent so that the value is
+		// parsed as a Float again
+		if text = strconv.FormatFloat(*v.Float, 'g', -1, 64); !strings.ContainsAny(text, ".eEnN") {
+			text += ".0"
+		}
+	case v.Bool != nil:
+		text = fmt.Sprintf("%v", *v.Bool)
+	case v.Nil != nil:
+		text = "nil"
+	case v.List != nil:
+		items := make([]string, len(v.List))
+		for idx, item := range v.List {
+			items[idx] = item.format(quoted)
 		}
+		text = "(" + strings.Join(items, ", ") + ")"
+	case v.Placeholder != nil:
+		text = *v.Placeholder
+	case v.Call != nil:
+		text = v.Call.format(quoted)
+	case v.Time != nil:
+		text = *v.Time
+	case v.Duration != nil:
+		text = *v.Duration
+	case v.Arithmetic != nil:
+		text = v.Arithmetic.format(quoted)
+	case v.Self != nil:
+		text = "."
 	}
 	return
 }
@@ -0,0 +1,132 @@
+// Copyright (c) 2024  The Go-CoreLibs Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cql
+
+// Node is any element of a statement syntax tree, one of: *Statement,
+// *Expression, *Condition, *Operation, *Quantifier or *Value
+type Node interface {
+	cqlNode()
+}
+
+func (*Statement) cqlNode()  {}
+func (*Expression) cqlNode() {}
+func (*Condition) cqlNode()  {}
+func (*Operation) cqlNode()  {}
+func (*Quantifier) cqlNode() {}
+func (*Value) cqlNode()      {}
+
+// Visitor is used with Walk, the Visit method is called for each node
+// encountered and if the Visitor w returned is not nil, Walk visits each of
+// the children of the node with w, followed by a call of w.Visit(nil)
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses the syntax tree in depth-first order, starting with a call
+// to v.Visit(node). Condition and Operation children are visited left then
+// right, followed by any list items, function call arguments and arithmetic
+// operands of values
+func Walk(v Visitor, node Node) {
+	if node == nil {
+		return
+	}
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Statement:
+		if n.Expression != nil {
+			Walk(v, n.Expression)
+		}
+	case *Expression:
+		if n.Condition != nil {
+			Walk(v, n.Condition)
+		}
+		if n.Operation != nil {
+			Walk(v, n.Operation)
+		}
+		if n.Quantifier != nil {
+			Walk(v, n.Quantifier)
+		}
+	case *Condition:
+		if n.Left != nil {
+			Walk(v, n.Left)
+		}
+		if n.Right != nil {
+			Walk(v, n.Right)
+		}
+	case *Operation:
+		if n.Left != nil {
+			Walk(v, n.Left)
+		}
+		if n.Right != nil {
+			Walk(v, n.Right)
+		}
+	case *Quantifier:
+		if n.Expression != nil {
+			Walk(v, n.Expression)
+		}
+	case *Value:
+		for _, item := range n.List {
+			if item != nil {
+				Walk(v, item)
+			}
+		}
+		if n.Call != nil {
+			for _, arg := range n.Call.Args {
+				if arg != nil {
+					Walk(v, arg)
+				}
+			}
+		}
+		if n.Arithmetic != nil {
+			if n.Arithmetic.Left != nil {
+				Walk(v, n.Arithmetic.Left)
+			}
+			if n.Arithmetic.Right != nil {
+				Walk(v, n.Arithmetic.Right)
+			}
+		}
+	}
+
+	v.Visit(nil)
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses the syntax tree in depth-first order, calling f for each
+// node encountered and if f returns true, Inspect continues with the children
+// of the node, followed by a call of f(nil)
+//
+// Example:
+//
+//	var forbidden bool
+//	cql.Inspect(stmnt, func(node cql.Node) bool {
+//	    if v, ok := node.(*cql.Value); ok && v.ContextKey != nil && *v.ContextKey == "Secret" {
+//	        forbidden = true
+//	    }
+//	    return !forbidden
+//	})
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
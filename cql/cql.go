@@ -27,29 +27,44 @@ import (
 )
 
 const (
-	gIdent      = `\b([a-zA-Z][.a-zA-Z0-9]*)\b`
+	gSegment    = `(?:[\p{L}_][\p{L}\p{N}_]*|"(?:\\.|[^"\\])+")`
+	gIdent      = gSegment + `(?:\[\d+\]|\.` + gSegment + `)*`
+	gKey        = `\.` + gIdent
+	gFuncName   = `\b[a-zA-Z_][a-zA-Z0-9_]*\b`
 	gInteger    = `\b(\d+)\b`
-	gFloat      = `\b(\d*\.\d+)\b`
-	gString     = `'[^']*'|"[^"]*"`
-	gRegexp     = `/(.+?)/|\!(.+?)\!|\@(.+?)\@|\~(.+?)\~`
-	gOperators  = `==|=\~|\!=|\!\~|[.,()]`
+	gFloat      = `(?:\d*\.)?\d+[eE][-+]?\d+\b|\d*\.\d+\b`
+	gDateTime   = `\b\d{4}-\d{2}-\d{2}(?:T\d{2}:\d{2}:\d{2}(?:\.\d+)?(?:Z|[-+]\d{2}:\d{2}))?\b`
+	gDuration   = `\b(?:\d+(?:\.\d+)?(?:ns|us|µs|ms|s|m|h|d))+\b`
+	gString     = `'(?:\\.|[^'\\])*'|"(?:\\.|[^"\\])*"`
+	gRegexp     = `m(?:/(?:\\.|[^/\\])+/|\!(?:\\.|[^!\\])+\!|\@(?:\\.|[^@\\])+\@|\~(?:\\.|[^~\\])+\~)[ims]*`
+	gHolder     = `\$\d+|:[a-zA-Z][a-zA-Z0-9]*`
+	gOperators  = `==\*|\!=\*|==|=\~|\!=|\!\~|<=|>=|[.,()<>+\-*/%]`
 	gWhitespace = `\s+`
 )
 
 var (
 	gLexer = lexer.MustSimple([]lexer.SimpleRule{
-		{Name: `Keyword`, Pattern: `(?i)\b(TRUE|FALSE|NULL|IS|NOT|AND|OR|IN)\b`},
-		{Name: `Ident`, Pattern: gIdent},
-		{Name: `Int`, Pattern: gInteger},
+		{Name: `Keyword`, Pattern: `(?i)\b(TRUE|FALSE|NULL|NIL|IS|NOT|AND|OR|IN|ANY|ALL)\b`},
+		{Name: `Regexp`, Pattern: gRegexp},
+		{Name: `Key`, Pattern: gKey},
+		{Name: `Ident`, Pattern: gFuncName},
+		{Name: `DateTime`, Pattern: gDateTime},
+		{Name: `Duration`, Pattern: gDuration},
 		{Name: `Float`, Pattern: gFloat},
+		{Name: `Int`, Pattern: gInteger},
 		{Name: `String`, Pattern: gString},
-		{Name: `Regexp`, Pattern: gRegexp},
+		{Name: `Placeholder`, Pattern: gHolder},
 		{Name: `Operators`, Pattern: gOperators},
 		{Name: `whitespace`, Pattern: gWhitespace},
 	})
-	gParser = participle.MustBuild[Statement](
+	gParser = participle.MustBuild[disjunction](
 		participle.Lexer(gLexer),
 		participle.CaseInsensitive("Keyword"),
+		participle.Map(func(token lexer.Token) (lexer.Token, error) {
+			// context keys are captured without the leading period
+			token.Value = token.Value[1:]
+			return token, nil
+		}, "Key"),
 	)
 )
 
@@ -57,28 +72,73 @@ func EBNF() (ebnf string) {
 	return gParser.String()
 }
 
+// Compile parses the given query into a new Statement, enforcing the
+// DefaultLimits
 func Compile(query string) (stmnt *Statement, err *ParseError) {
+	return CompileWithLimits(query, DefaultLimits)
+}
+
+// CompileWithLimits is like Compile and enforces the given Limits instead of
+// the DefaultLimits, any limit exceeded is reported as a ParseError wrapping a
+// LimitError
+//
+// The limits are retained by the Statement for use during evaluation, see
+// Statement.Limits
+func CompileWithLimits(query string, limits Limits) (stmnt *Statement, err *ParseError) {
 	err = nil
 	query = strings.TrimSpace(query)
 
 	var participleError error
-	if stmnt, participleError = gParser.ParseString("cql", query); participleError != nil && participleError.Error() != "" {
+	if participleError = limits.checkQuery(query); participleError != nil {
 		err = newParseError(query, participleError)
 		return
 	}
 
+	var tree *disjunction
+	if tree, participleError = gParser.ParseString("cql", query); participleError != nil && participleError.Error() != "" {
+		err = newParseError(query, participleError)
+		return
+	}
+	stmnt = &Statement{Expression: tree.expression(), limits: limits}
+
+	if participleError = validateExpression(stmnt.Expression, true); participleError != nil {
+		err = newParseError(query, participleError)
+		return
+	}
+
+	if participleError = limits.checkExpression(stmnt.Expression, true); participleError != nil {
+		err = newParseError(query, participleError)
+		return
+	}
+
+	stmnt.ContextKeys = extractContextKeys(stmnt.Expression)
+	return
+}
+
+// extractContextKeys returns the natural sorted list of unique context keys
+// referenced by the given expression
+func extractContextKeys(expr *Expression) (contextKeys []string) {
 	var extract func(expr *Expression) (keys []string)
 	extract = func(expr *Expression) (keys []string) {
 		unique := make(map[string]bool)
 		switch {
 		case expr.Operation != nil:
-			unique[*expr.Operation.Left] = true
-			if expr.Operation.Right.ContextKey != nil {
-				unique[*expr.Operation.Right.ContextKey] = true
+			for _, key := range expr.Operation.Left.contextKeys() {
+				unique[key] = true
+			}
+			if expr.Operation.Right != nil {
+				for _, key := range expr.Operation.Right.contextKeys() {
+					unique[key] = true
+				}
 			}
+		case expr.Quantifier != nil:
+			// the keys within the quantified expression refer to the items
+			unique[expr.Quantifier.ContextKey] = true
 		case expr.Condition != nil:
-			for _, key := range extract(expr.Condition.Left) {
-				unique[key] = true
+			if expr.Condition.Left != nil {
+				for _, key := range extract(expr.Condition.Left) {
+					unique[key] = true
+				}
 			}
 			for _, key := range extract(expr.Condition.Right) {
 				unique[key] = true
@@ -88,8 +148,7 @@ func Compile(query string) (stmnt *Statement, err *ParseError) {
 		return
 	}
 
-	contextKeys := extract(stmnt.Expression)
+	contextKeys = extract(expr)
 	sort.Sort(natural.StringSlice(contextKeys))
-	stmnt.ContextKeys = contextKeys
 	return
 }
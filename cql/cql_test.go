@@ -0,0 +1,731 @@
+// Copyright (c) 2024  The Go-CoreLibs Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cql
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCompile(t *testing.T) {
+	Convey("Round Trip", t, func() {
+		for _, test := range []struct {
+			query  string
+			output string
+			keys   []string
+		}{
+			{`(.Title =~ m/^Draft/)`, `(.Title =~ m/^Draft/)`, []string{"Title"}},
+			{`((.A !~ m!x!) AND (.B != 'y'))`, `((.A !~ m!x!) AND (.B != 'y'))`, []string{"A", "B"}},
+			{`(.Count == 1.5)`, `(.Count == 1.5)`, []string{"Count"}},
+			{`((.A <= 1) OR (.B>.C))`, `((.A <= 1) OR (.B > .C))`, []string{"A", "B", "C"}},
+			{`(.A >= 10)`, `(.A >= 10)`, []string{"A"}},
+			{`(.A IN ('a', 1,.B))`, `(.A IN ('a', 1, .B))`, []string{"A", "B"}},
+			{`(.A in .B)`, `(.A IN .B)`, []string{"A", "B"}},
+			{`((.A is null) OR (.B IS NOT NULL))`, `((.A IS NULL) OR (.B IS NOT NULL))`, []string{"A", "B"}},
+			{`((.A == 1))`, `(.A == 1)`, []string{"A"}},
+			{`.A == 1 AND .B == 2 AND .C == 3 OR .D == 4`, `((((.A == 1) AND (.B == 2)) AND (.C == 3)) OR (.D == 4))`, []string{"A", "B", "C", "D"}},
+			{`.A == 1 OR .B == 2 AND .C == 3`, `((.A == 1) OR ((.B == 2) AND (.C == 3)))`, []string{"A", "B", "C"}},
+			{`(.A == 1 OR .B == 2) AND .C == 3`, `(((.A == 1) OR (.B == 2)) AND (.C == 3))`, []string{"A", "B", "C"}},
+			{`NOT (.A == 1) and not .B IS NULL`, `((NOT (.A == 1)) AND (NOT (.B IS NULL)))`, []string{"A", "B"}},
+			{`.Author.Name == .Items[0].Authors[1].Name`, `(.Author.Name == .Items[0].Authors[1].Name)`, []string{"Author.Name", "Items[0].Authors[1].Name"}},
+			{`.A == $1 AND .B IN (:list, 'x')`, `((.A == $1) AND (.B IN (:list, 'x')))`, []string{"A", "B"}},
+			{`.A == 'it\'s' OR .A == "say \"hi\"\u00e9"`, `((.A == 'it\'s') OR (.A == "say \"hi\"\u00e9"))`, []string{"A"}},
+			{`.A =~ m/^a\/b$/i AND .B !~ m!x!ms`, `((.A =~ m/^a\/b$/i) AND (.B !~ m!x!ms))`, []string{"A", "B"}},
+			{`NOT NOT .A == 1`, `(NOT (NOT (.A == 1)))`, []string{"A"}},
+			{`.A ==* 'News' AND .B!=*.C`, `((.A ==* 'News') AND (.B !=* .C))`, []string{"A", "B", "C"}},
+			{`.A > -10 AND .B<+2.5 AND .C >= -.5`, `(((.A > -10) AND (.B < 2.5)) AND (.C >= -0.5))`, []string{"A", "B", "C"}},
+			{`.A == 1e3 OR .B == 1.5E-3 OR .C == 3.0`, `(((.A == 1000.0) OR (.B == 0.0015)) OR (.C == 3.0))`, []string{"A", "B", "C"}},
+			{`.A == 1e21`, `(.A == 1e+21)`, []string{"A"}},
+			{`.Ünïcode_ключ == .日本.語[0]`, `(.Ünïcode_ключ == .日本.語[0])`, []string{"Ünïcode_ключ", "日本.語[0]"}},
+			{`contains(.Title,'go')`, `(contains(.Title, 'go'))`, []string{"Title"}},
+			{`NOT startsWith(.Slug, $1) AND len(.Tags)>2`, `((NOT (startsWith(.Slug, $1))) AND (len(.Tags) > 2))`, []string{"Slug", "Tags"}},
+			{`lower(.Category) == 'news' OR .A IN (upper(.B), 'X')`, `((lower(.Category) == 'news') OR (.A IN (upper(.B), 'X')))`, []string{"A", "B", "Category"}},
+			{`endsWith(lower(.Title), .Suffix)`, `(endsWith(lower(.Title), .Suffix))`, []string{"Suffix", "Title"}},
+			{`.Published > now() - 30d`, `(.Published > now() - 30d)`, []string{"Published"}},
+			{`now()-7d<=.A AND .B == 2024-01-02`, `((now() - 7d <= .A) AND (.B == 2024-01-02))`, []string{"A", "B"}},
+			{`.A > 2024-01-02T10:00:00Z OR .A < 2024-01-02T10:00:00.5+01:00`, `((.A > 2024-01-02T10:00:00Z) OR (.A < 2024-01-02T10:00:00.5+01:00))`, []string{"A"}},
+			{`.D IN (-1d12h, 36h, 500ms) AND .A - .B > 1h30m + 5s`, `((.D IN (-1d12h, 36h, 500ms)) AND (.A - .B > 1h30m + 5s))`, []string{"A", "B", "D"}},
+			{`.Meta."publish-date" > '2024' AND ."a b"[1] IS NULL`, `((.Meta."publish-date" > '2024') AND (."a b"[1] IS NULL))`, []string{`"a b"[1]`, `Meta."publish-date"`}},
+		} {
+			stmnt, err := Compile(test.query)
+			So(err, ShouldBeNil)
+			So(stmnt.String(), ShouldEqual, test.output)
+			So(stmnt.ContextKeys, ShouldEqual, test.keys)
+			again, err := Compile(stmnt.String())
+			So(err, ShouldBeNil)
+			So(again.String(), ShouldEqual, test.output)
+		}
+	})
+	Convey("Parse Errors", t, func() {
+		for _, query := range []string{
+			`(.A IS 1)`,
+			`(.A IS NOT 1)`,
+			`(.A IS NULL 1)`,
+			`(.A == )`,
+			`(.A IN 'a')`,
+			`(.A IN ('a', ('b')))`,
+			`(.A == ('a'))`,
+			`(.A == 1`,
+			`.A == 'bad \q escape'`,
+			`.A == 'unterminated`,
+			`.A =~ m/x/z`,
+			`.A == 1 AND`,
+			`NOT`,
+			``,
+			`.A == --1`,
+			`.A == 1e`,
+			`.A."" == 1`,
+			`.A.-b == 1`,
+			`.1A == 1`,
+			`missing(.A)`,
+			`len(.A, .B) > 1`,
+			`contains(.A) AND .B == 1`,
+			`contains(.A, ('x'))`,
+			`lower(.A) IS NULL 1`,
+			`1 == .A`,
+			`.A > 2024-13-02`,
+			`.A > 2024-01-02T25:00:00Z`,
+			`.A > 5y`,
+			`.A == 'x' - 1d`,
+			`.A == 1d - now()`,
+			`.A - 1`,
+			`.A > now(1)`,
+			`now()`,
+		} {
+			_, err := Compile(query)
+			So(err, ShouldNotBeNil)
+		}
+	})
+	Convey("Format", t, func() {
+		for _, test := range []struct {
+			query     string
+			canonical string
+		}{
+			{`.A == "x"`, `(.A == 'x')`},
+			{`.A == "it's"`, `(.A == "it's")`},
+			{`.A =~ m!^a/b$!i`, `(.A =~ m!(?i)^a/b$!)`},
+			{`."B" == .A`, `(.A == .B)`},
+			{`.B ==* .A`, `(.A ==* .B)`},
+			{`.B != ."A"`, `(.A != .B)`},
+			{`.B < .A`, `(.B < .A)`},
+			{`.M."a-b"[0]."c" is not null`, `(.M."a-b"[0].c IS NOT NULL)`},
+			{`.C == 1 and .B == 2 and .A == 3`, `((.A == 3) AND (.B == 2) AND (.C == 1))`},
+			{`.C == 1 or (.B == 2 or .A == 3.0)`, `((.A == 3.0) OR (.B == 2) OR (.C == 1))`},
+			{`.C == 1 or .B == 2 and .A == TRUE`, `(((.A == true) AND (.B == 2)) OR (.C == 1))`},
+			{`not (.B == 1 and .A == nil)`, `(NOT ((.A == nil) AND (.B == 1)))`},
+			{`.A in ("x", .B, m/y/)`, `(.A IN ('x', .B, m/y/))`},
+			{`lower(."B") == lower(.A)`, `(lower(.A) == lower(.B))`},
+			{`contains(."Tags", "x")`, `(contains(.Tags, 'x'))`},
+		} {
+			stmnt, err := Compile(test.query)
+			So(err, ShouldBeNil)
+			So(stmnt.Format(), ShouldEqual, test.canonical)
+			So(stmnt.Render().Format(), ShouldEqual, test.canonical)
+			again, err := Compile(test.canonical)
+			So(err, ShouldBeNil)
+			So(again.Format(), ShouldEqual, test.canonical)
+			So(stmnt.Equal(again), ShouldBeTrue)
+		}
+
+		a, _ := Compile(`.A == 'x' AND (.B == 1 OR .C == 2)`)
+		b, _ := Compile(`(.C == 2 OR .B == 1) AND .A == "x"`)
+		c, _ := Compile(`.A == 'x' AND .B == 1 OR .C == 2`)
+		So(a.Equal(b), ShouldBeTrue)
+		So(a.Equal(c), ShouldBeFalse)
+		So(a.Equal(nil), ShouldBeFalse)
+
+		rendered := a.Render()
+		So(rendered.String(), ShouldEqual, a.String())
+		So(rendered.Equal(a), ShouldBeTrue)
+	})
+
+	Convey("JSON", t, func() {
+		for _, query := range []string{
+			`.A == 'it\'s' OR .B =~ m/^x$/i`,
+			`NOT (.A IN ('x', 1, 2.5, true, nil, .B) AND .C IS NOT NULL)`,
+			`.Meta."publish-date" >= -10 AND .D == $1`,
+			`contains(.Tags, 'x') OR len(lower(.A)) > 2`,
+			`.A > now() - 30d AND .B IN (2024-01-02, 1d)`,
+		} {
+			stmnt, err := Compile(query)
+			So(err, ShouldBeNil)
+			loaded, e := CompileJSON([]byte(stmnt.Stringify()))
+			So(e, ShouldBeNil)
+			So(loaded.Equal(stmnt), ShouldBeTrue)
+			So(loaded.ContextKeys, ShouldEqual, stmnt.ContextKeys)
+			So(loaded.Stringify(), ShouldEqual, stmnt.Stringify())
+			again, err := Compile(loaded.String())
+			So(err, ShouldBeNil)
+			So(again.Equal(stmnt), ShouldBeTrue)
+		}
+
+		loaded, e := CompileJSON([]byte(`{"expressions": {"operation": {"left": "Title", "type": "==", "right": {"string": "it's"}}}, "context-keys": ["Wrong"]}`))
+		So(e, ShouldBeNil)
+		So(loaded.String(), ShouldEqual, `(.Title == "it's")`)
+		So(loaded.ContextKeys, ShouldEqual, []string{"Title"})
+
+		for _, data := range []string{
+			`{`,
+			`{}`,
+			`{"expressions": {}}`,
+			`{"expressions": {"condition": {"type": "NOT", "right": {"operation": {"left": "A", "type": "IS NULL"}}}, "operation": {"left": "A", "type": "IS NULL"}}}`,
+			`{"expressions": {"operation": {"left": "A", "type": "==", "right": {"string": "x", "int": 1}}}}`,
+			`{"expressions": {"operation": {"left": "A", "type": "==", "right": {}}}}`,
+			`{"expressions": {"operation": {"left": "A", "type": "<>", "right": {"int": 1}}}}`,
+			`{"expressions": {"operation": {"left": "A B", "type": "==", "right": {"int": 1}}}}`,
+			`{"expressions": {"condition": {"type": "XOR", "right": {"operation": {"left": "A", "type": "IS NULL"}}}}}`,
+			`{"expressions": {"condition": {"type": "AND", "right": {"operation": {"left": "A", "type": "IS NULL"}}}}}`,
+		} {
+			_, e = CompileJSON([]byte(data))
+			So(e, ShouldNotBeNil)
+		}
+
+		_, e = CompileJSONWithLimits([]byte(loaded.Stringify()), Limits{MaxOperations: 1})
+		So(e, ShouldBeNil)
+		deep, _ := Compile(`NOT (NOT (NOT (NOT (NOT (.A == 'x' OR .B =~ m/y/)))))`)
+		_, e = CompileJSONWithLimits([]byte(deep.Stringify()), Limits{MaxDepth: 1})
+		var depthErr *LimitError
+		So(errors.As(e, &depthErr), ShouldBeTrue)
+		So(depthErr.Limit, ShouldEqual, "MaxDepth")
+
+		// loaded statements are in query syntax form, the same as Compile,
+		// and so can be rewritten using the builders
+		loaded, e = CompileJSONWithLimits([]byte(deep.Stringify()), Limits{MaxDepth: 8})
+		So(e, ShouldBeNil)
+		So(loaded.String(), ShouldEqual, deep.String())
+		for _, stmnt := range []*Statement{loaded, loaded.Render()} {
+			scoped, e := stmnt.Rewrite(func(node Node) (Node, error) {
+				if s, ok := node.(*Statement); ok {
+					s.Expression = And(Eq("Tenant", StringValue("acme")), s.Expression)
+				}
+				return node, nil
+			})
+			So(e, ShouldBeNil)
+			So(scoped.String(), ShouldStartWith, `((.Tenant == 'acme') AND`)
+			So(*scoped.Render().Expression.Condition.Left.Operation.Right.String, ShouldEqual, "acme")
+			_, e = stmnt.Rewrite(func(node Node) (Node, error) {
+				if s, ok := node.(*Statement); ok {
+					s.Expression = Not(Not(s.Expression))
+				}
+				return node, nil
+			})
+			So(errors.As(e, &depthErr), ShouldBeTrue)
+		}
+		_, e = CompileJSONWithLimits([]byte(`{"expressions": {"operation": {"left": "A", "type": "=~", "right": {"regexp": "abcdef"}}}}`), Limits{MaxRegexpLength: 3})
+		var limitErr *LimitError
+		So(errors.As(e, &limitErr), ShouldBeTrue)
+	})
+
+	Convey("Diagnostics", t, func() {
+		for _, test := range []struct {
+			query    string
+			line     int
+			column   int
+			token    string
+			expected string
+			message  string
+		}{
+			{`.A == 'x: y' AND .B IS 1`, 1, 24, `1`, `"NULL"`, `unexpected token "1" (expected "NULL")`},
+			{`.A == 1 AND .B ~= 2`, 1, 16, `~=`, ``, `invalid input text "~= 2"`},
+			{`.A == 1 AND`, 1, 12, ``, `Expression`, `unexpected token "<EOF>" (expected Expression)`},
+			{`.A == 1 OR OR .B == 1`, 1, 12, `OR`, `Expression`, `unexpected token "OR" (expected Expression)`},
+			{`NOT`, 1, 4, ``, `Expression`, ``},
+			{`ANY .Tags (`, 1, 12, ``, `Expression`, ``},
+			{`.A ==`, 1, 6, ``, `Value`, `unexpected token "<EOF>" (expected Value)`},
+			{`.A + `, 1, 5, ``, `Value`, ``},
+			{`.B IN ()`, 1, 8, `)`, `Value`, `unexpected token ")" (expected Value)`},
+			{`.A == m//`, 1, 8, `/`, `"("`, `unexpected token "/" (expected "(")`},
+			{`ANY .Tags`, 1, 10, ``, `"("`, `unexpected token "<EOF>" (expected "(")`},
+			{`.A == 1 OR .B IS`, 1, 17, ``, `"NULL"`, `unexpected token "<EOF>" (expected "NULL")`},
+			{`.A == 1 AND .B IN 'a'`, 1, 19, `'a'`, ``, `IN expects a list, context key or placeholder`},
+			{`.A == unknownFn(1)`, 1, 7, `unknownFn`, ``, `unknown function "unknownFn"`},
+			{`.A == lower(1)`, 1, 13, `1`, ``, `lower argument 1 is of type number, expected string`},
+			{`.A > 2024-13-02`, 1, 6, `2024-13-02`, ``, `invalid time literal "2024-13-02"`},
+			{".A == 1\n  AND .B IS NOT", 2, 16, ``, `"NULL"`, `unexpected token "<EOF>" (expected "NULL")`},
+			{".A == 1 AND\n (.B == 2) .C", 2, 12, `.C`, ``, `unexpected token ".C"`},
+		} {
+			_, err := Compile(test.query)
+			So(err, ShouldNotBeNil)
+			So(err.Line, ShouldEqual, test.line)
+			So(err.Column, ShouldEqual, test.column)
+			So(err.Token, ShouldEqual, test.token)
+			if test.expected != "" {
+				So(err.Expected, ShouldEqual, test.expected)
+			}
+			if test.message != "" {
+				So(err.Message, ShouldEqual, test.message)
+				So(err.Error(), ShouldEqual, fmt.Sprintf("cql:%d:%d: %s", test.line, test.column, test.message))
+			}
+		}
+
+		_, err := Compile(".A == 1 AND\n\t.B IS 'x'")
+		So(err.Pretty(), ShouldEqual, "2 | \t.B IS 'x'\n    \t      ^- error: unexpected token \"'x'\" (expected \"NULL\")\n")
+		_, err = Compile(`.A == 1 AND .B ~= 2`)
+		So(err.Pretty(), ShouldEqual, ".A == 1 AND .B ~= 2\n               ^- error: invalid input text \"~= 2\"\n")
+	})
+
+	Convey("Suggest", t, func() {
+		stmnt, err := Compile(`.Titel == 'x' AND (.Tags IN .tags OR .Author.Nmae == .Autor)`)
+		So(err, ShouldBeNil)
+		known := []string{"Title", "Tags", "Author", "Author.Name", "Type"}
+		suggestions := stmnt.Suggest(known, nil)
+		So(suggestions, ShouldHaveLength, 4)
+		So(suggestions[0].String(), ShouldEqual, `unknown context key .Author.Nmae, did you mean .Author.Name?`)
+		So(suggestions[0].Pos.Column, ShouldEqual, 38)
+		So(suggestions[1].String(), ShouldEqual, `unknown context key .Autor, did you mean .Author?`)
+		So(suggestions[2].String(), ShouldEqual, `unknown context key .Titel, did you mean .Title?`)
+		So(suggestions[2].Pos.Column, ShouldEqual, 1)
+		So(suggestions[3].String(), ShouldEqual, `unknown context key .tags, did you mean .Tags?`)
+
+		suggestions = stmnt.Suggest(known, func(key string) bool {
+			return key != "Titel"
+		})
+		So(suggestions, ShouldHaveLength, 1)
+
+		stmnt, _ = Compile(`.Unrelated == 1 AND .Typ == 2`)
+		suggestions = stmnt.Suggest(known, nil)
+		So(suggestions, ShouldHaveLength, 2)
+		So(suggestions[0].Candidates, ShouldEqual, []string{"Type"})
+		So(suggestions[1].Candidates, ShouldBeEmpty)
+		So(suggestions[1].String(), ShouldEqual, `unknown context key .Unrelated`)
+	})
+
+	Convey("Limits", t, func() {
+		limits := Limits{MaxLength: 20, MaxDepth: 1}
+		_, err := CompileWithLimits(`.Title == 'something long'`, limits)
+		So(err, ShouldNotBeNil)
+		So(err.Column, ShouldEqual, 21)
+		So(err.Message, ShouldEqual, `query length of 26 exceeds the limit of 20`)
+		var limitErr *LimitError
+		So(errors.As(err, &limitErr), ShouldBeTrue)
+		So(limitErr.Max, ShouldEqual, 20)
+		So(limitErr.Size, ShouldEqual, 26)
+
+		_, err = CompileWithLimits(`(.A==1) OR ((.B==2))`, limits)
+		So(err, ShouldNotBeNil)
+		So(err.Column, ShouldEqual, 2)
+		So(err.Message, ShouldEqual, `nesting depth of 2 exceeds the limit of 1`)
+
+		// the depth is that of the parsed statement, not of the parentheses
+		limits = Limits{MaxDepth: 3}
+		for _, test := range []struct {
+			query string
+			depth int
+		}{
+			{`((((.A == 1))))`, 0},
+			{`.A == 1 AND .B == 2 AND .C == 3 AND .D == 4`, 0},
+			{`.A == 1 AND (.B == 2 OR .C == 3)`, 0},
+			{`NOT NOT .A == 1`, 0},
+			{`NOT NOT NOT .A == 1`, 4},
+			{`.A == 1 AND (.B == 2 OR NOT .C == 3)`, 4},
+			{`ANY .A (ANY .B (.C == 1))`, 0},
+			{`ANY .A (ANY .B (ANY .C (.D == 1)))`, 4},
+			{`.A == .B + 1`, 0},
+			{`.A == .B + 1 - 2`, 0},
+			{`.A == .B + 1 - 2 + 3`, 4},
+			{`.A == .B + 2 * 3 * 4`, 4},
+			{`.A == lower(upper(.B))`, 0},
+			{`.A == lower(upper(lower(.B)))`, 4},
+		} {
+			_, err = CompileWithLimits(test.query, limits)
+			if test.depth == 0 {
+				So(err, ShouldBeNil)
+			} else {
+				So(err, ShouldNotBeNil)
+				So(errors.As(err, &limitErr), ShouldBeTrue)
+				So(limitErr.Limit, ShouldEqual, "MaxDepth")
+				So(limitErr.Size, ShouldEqual, test.depth)
+			}
+		}
+		limits = Limits{MaxLength: 20, MaxDepth: 1}
+
+		stmnt, err := CompileWithLimits(`.A IN ('a', 'b')`, limits)
+		So(err, ShouldBeNil)
+		So(stmnt.Limits(), ShouldEqual, limits)
+		So(stmnt.Render().Limits(), ShouldEqual, limits)
+	})
+
+	Convey("Bind", t, func() {
+		stmnt, err := Compile(`.A == $1 AND .B IN (:list, 'x') AND .C =~ $2 OR .D IN $3`)
+		So(err, ShouldBeNil)
+		So(stmnt.HasPlaceholders(), ShouldBeTrue)
+
+		bound, e := stmnt.Bind(
+			"it's ) OR (.Admin == true",
+			regexp.MustCompile(`^a/b$`),
+			[]int{1, 2},
+			Named("list", []string{"y", "z"}),
+		)
+		So(e, ShouldBeNil)
+		So(bound.HasPlaceholders(), ShouldBeFalse)
+		So(bound.String(), ShouldEqual, `((((.A == "it's ) OR (.Admin == true") AND (.B IN ('y', 'z', 'x'))) AND (.C =~ m!^a/b$!)) OR (.D IN (1, 2)))`)
+		So(bound.ContextKeys, ShouldEqual, stmnt.ContextKeys)
+		// the original is unchanged
+		So(stmnt.String(), ShouldEqual, `((((.A == $1) AND (.B IN (:list, 'x'))) AND (.C =~ $2)) OR (.D IN $3))`)
+
+		rendered, e := stmnt.Render().Bind("text", regexp.MustCompile(`x`), []int{1}, Named("list", nil))
+		So(e, ShouldBeNil)
+		So(*rendered.Expression.Condition.Left.Condition.Left.Condition.Left.Operation.Right.String, ShouldEqual, "text")
+
+		_, e = stmnt.Bind("only one")
+		So(e, ShouldNotBeNil)
+		_, e = stmnt.Bind("a", "b", 3, Named("other", "c"))
+		So(e, ShouldNotBeNil)
+		_, e = stmnt.Bind("a", "b", struct{}{}, Named("list", "c"))
+		So(e, ShouldNotBeNil)
+		_, e = stmnt.Bind([]string{"a"}, "b", 3, Named("list", "c"))
+		So(e, ShouldNotBeNil)
+
+		stmnt, err = Compile(`.A == $1 OR .B == $2`)
+		So(err, ShouldBeNil)
+		bound, e = stmnt.Bind(uint64(math.MaxUint64), uint8(7))
+		So(e, ShouldBeNil)
+		So(bound.String(), ShouldEqual, `((.A == 1.8446744073709552e+19) OR (.B == 7))`)
+		So(*bound.Expression.Condition.Left.Operation.Right.Float, ShouldEqual, float64(math.MaxUint64))
+		_, err = Compile(bound.String())
+		So(err, ShouldBeNil)
+	})
+	Convey("Quoting", t, func() {
+		So(QuoteKey("Title"), ShouldEqual, `Title`)
+		So(QuoteKey("ключ_2"), ShouldEqual, `ключ_2`)
+		So(QuoteKey("publish-date"), ShouldEqual, `"publish-date"`)
+		So(QuoteKey(`say "hi"`), ShouldEqual, `"say \"hi\""`)
+
+		for _, test := range []struct {
+			text   string
+			quoted string
+		}{
+			{`plain`, `'plain'`},
+			{`it's`, `"it's"`},
+			{`say "hi"`, `'say "hi"'`},
+			{`both ' and "`, `'both \' and "'`},
+			{"tab\there\n", `'tab\there\n'`},
+			{`back\slash`, `'back\\slash'`},
+			{`über ☺`, `'über ☺'`},
+		} {
+			So(QuoteString(test.text), ShouldEqual, test.quoted)
+			text, err := UnquoteString(test.quoted)
+			So(err, ShouldBeNil)
+			So(text, ShouldEqual, test.text)
+		}
+
+		text, err := UnquoteString(`'\u00fcber \x41\''`)
+		So(err, ShouldBeNil)
+		So(text, ShouldEqual, "über A'")
+		for _, test := range []struct {
+			quoted string
+			text   string
+		}{
+			{`"a\'b"`, `a'b`},
+			{`'say \"hi\"'`, `say "hi"`},
+			{`"say \"hi\""`, `say "hi"`},
+			{`'both \' and \"'`, `both ' and "`},
+		} {
+			text, err = UnquoteString(test.quoted)
+			So(err, ShouldBeNil)
+			So(text, ShouldEqual, test.text)
+			stmnt, e := Compile(`.A == ` + test.quoted)
+			So(e, ShouldBeNil)
+			So(*stmnt.Render().Expression.Operation.Right.String, ShouldEqual, test.text)
+		}
+		for _, bad := range []string{`'\q'`, `'nope`, `nope'`, `'`, `"it"s"`} {
+			_, err = UnquoteString(bad)
+			So(err, ShouldNotBeNil)
+		}
+
+		for _, test := range []struct {
+			pattern string
+			quoted  string
+		}{
+			{`^draft`, `m/^draft/`},
+			{`^a/b$`, `m!^a/b$!`},
+			{`/!@~`, `m/\/!@~/`},
+			{`\d+\/x`, `m!\d+\/x!`},
+		} {
+			So(QuoteRegexp(test.pattern), ShouldEqual, test.quoted)
+			pattern, err := UnquoteRegexp(test.quoted)
+			So(err, ShouldBeNil)
+			So(regexp.MustCompile(pattern).String(), ShouldEqual, regexp.MustCompile(test.pattern).String())
+		}
+
+		pattern, err := UnquoteRegexp(`m/^a\/b$/is`)
+		So(err, ShouldBeNil)
+		So(pattern, ShouldEqual, `(?is)^a/b$`)
+		_, err = UnquoteRegexp(`m/x/q`)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Registered Functions", t, func() {
+		defer UnregisterFunc("cqlTestVisible")
+		defer UnregisterFunc("cqlTestTitle")
+		So(RegisterFunc("cqlTestVisible", Signature{Args: []Type{StringType, NumberType}, Result: BoolType}), ShouldBeNil)
+		So(RegisterFunc("cqlTestTitle", Signature{Args: []Type{AnyType}, Result: StringType}), ShouldBeNil)
+
+		sig, ok := LookupFunc("cqlTestVisible")
+		So(ok, ShouldBeTrue)
+		So(sig.Args, ShouldEqual, []Type{StringType, NumberType})
+		_, ok = LookupFunc("cqlTestMissing")
+		So(ok, ShouldBeFalse)
+
+		for _, query := range []string{
+			`cqlTestVisible('en', 1)`,
+			`cqlTestVisible(.Locale, .Level) AND .A == 1`,
+			`cqlTestVisible(cqlTestTitle(.A), len(.B))`,
+			`cqlTestTitle(.A) == 'x'`,
+			`cqlTestVisible($1, :level)`,
+		} {
+			_, err := Compile(query)
+			So(err, ShouldBeNil)
+		}
+
+		for _, test := range []struct {
+			query string
+			err   string
+		}{
+			{`cqlTestVisible('en')`, "cqlTestVisible expects 2 argument(s), found 1"},
+			{`cqlTestVisible(1, 1)`, "cqlTestVisible argument 1 is of type number, expected string"},
+			{`cqlTestVisible('en', 'x')`, "cqlTestVisible argument 2 is of type string, expected number"},
+			{`cqlTestVisible(lower(.A), cqlTestTitle(.B))`, "cqlTestVisible argument 2 is of type string, expected number"},
+			{`startsWith(.A, 1)`, "startsWith argument 2 is of type number, expected string"},
+			{`cqlTestTitle(.A)`, "cqlTestTitle returns string, expected bool"},
+			{`len(.A)`, "len returns number, expected bool"},
+			{`cqlTestMissing(.A)`, `unknown function "cqlTestMissing"`},
+		} {
+			_, err := Compile(test.query)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, test.err)
+		}
+
+		stmnt, err := Compile(`cqlTestVisible($1, 1)`)
+		So(err, ShouldBeNil)
+		_, e := stmnt.Bind(2)
+		So(e, ShouldNotBeNil)
+		_, e = stmnt.Bind("en")
+		So(e, ShouldBeNil)
+
+		So(RegisterFunc("cqlTestVisible", Signature{Result: BoolType}), ShouldNotBeNil)
+		So(RegisterFunc("contains", Signature{Result: BoolType}), ShouldNotBeNil)
+		So(RegisterFunc("and", Signature{Result: BoolType}), ShouldNotBeNil)
+		So(RegisterFunc("bad-name", Signature{Result: BoolType}), ShouldNotBeNil)
+		So(RegisterFunc("cqlTestBadArg", Signature{Args: []Type{"date"}, Result: BoolType}), ShouldNotBeNil)
+		So(RegisterFunc("cqlTestBadResult", Signature{}), ShouldNotBeNil)
+
+		So(UnregisterFunc("cqlTestTitle"), ShouldBeTrue)
+		So(UnregisterFunc("cqlTestTitle"), ShouldBeFalse)
+		So(UnregisterFunc("contains"), ShouldBeFalse)
+		_, err = Compile(`cqlTestTitle(.A) == 'x'`)
+		So(err, ShouldNotBeNil)
+		So(RegisterFunc("cqlTestTitle", Signature{Args: []Type{AnyType}, Result: StringType}), ShouldBeNil)
+	})
+
+	Convey("Times and Durations", t, func() {
+		for _, test := range []struct {
+			text     string
+			duration time.Duration
+		}{
+			{`7d`, 7 * 24 * time.Hour},
+			{`36h`, 36 * time.Hour},
+			{`-1d12h30m`, -(36*time.Hour + 30*time.Minute)},
+			{`+1.5d`, 36 * time.Hour},
+			{`500ms`, 500 * time.Millisecond},
+			{`1h0m5s`, time.Hour + 5*time.Second},
+		} {
+			d, err := ParseDuration(test.text)
+			So(err, ShouldBeNil)
+			So(d, ShouldEqual, test.duration)
+		}
+		for _, text := range []string{``, `-`, `5`, `5y`, `1dx`, `--1d`, `d`} {
+			_, err := ParseDuration(text)
+			So(err, ShouldNotBeNil)
+		}
+
+		tm, err := ParseTime(`2024-01-02`)
+		So(err, ShouldBeNil)
+		So(tm, ShouldEqual, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC))
+		tm, err = ParseTime(`2024-01-02T15:04:05.5+01:00`)
+		So(err, ShouldBeNil)
+		So(tm.Equal(time.Date(2024, 1, 2, 14, 4, 5, 5e8, time.UTC)), ShouldBeTrue)
+		_, err = ParseTime(`2024-02-30`)
+		So(err, ShouldNotBeNil)
+
+		stmnt, err := NewStatement(And(
+			Gt("Published", ArithmeticValue(CallValue("now"), "-", DurationValue(30*24*time.Hour))),
+			Lt("Published", TimeValue(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC))),
+			Ne("Updated", TimeValue(time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC))),
+			In("Timeout", DurationValue(90*time.Minute), DurationValue(-25*time.Hour), DurationValue(0)),
+		))
+		So(err, ShouldBeNil)
+		So(stmnt.String(), ShouldEqual, `((((.Published > now() - 30d) AND (.Published < 2024-01-02)) AND (.Updated != 2024-01-02T15:04:05Z)) AND (.Timeout IN (1h30m, -1d1h, 0s)))`)
+
+		stmnt, _ = Compile(`.A > $1 - :ago`)
+		bound, e := stmnt.Bind(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), Named("ago", 36*time.Hour))
+		So(e, ShouldBeNil)
+		So(bound.String(), ShouldEqual, `(.A > 2024-01-02 - 1d12h)`)
+		_, e = stmnt.Bind(1, Named("ago", 36*time.Hour))
+		So(e, ShouldNotBeNil)
+
+		_, err = NewStatement(Gt("A", ArithmeticValue(KeyValue("B"), "-", ArithmeticValue(KeyValue("C"), "-", KeyValue("D")))))
+		So(err, ShouldNotBeNil)
+		_, err = NewStatement(Gt("A", ArithmeticValue(KeyValue("B"), "^", KeyValue("C"))))
+		So(err, ShouldNotBeNil)
+
+		stmnt, _ = Compile(`.A > .B - .C`)
+		var keys []string
+		Inspect(stmnt, func(node Node) bool {
+			if v, ok := node.(*Value); ok && v.ContextKey != nil {
+				keys = append(keys, *v.ContextKey)
+			}
+			return true
+		})
+		So(keys, ShouldEqual, []string{"A", "B", "C"})
+		renamed, err := stmnt.Rewrite(RenameKey("C", "D"))
+		So(err, ShouldBeNil)
+		So(renamed.String(), ShouldEqual, `(.A > .B - .D)`)
+		So(renamed.ContextKeys, ShouldEqual, []string{"A", "B", "D"})
+	})
+
+	Convey("Quantifiers", t, func() {
+		for _, test := range []struct {
+			query  string
+			output string
+			keys   []string
+		}{
+			{`ANY .Authors (.Name == 'Ann')`, `(ANY .Authors (.Name == 'Ann'))`, []string{"Authors"}},
+			{`all .Tags (. =~ m/^[a-z]+$/)`, `(ALL .Tags (. =~ m/^[a-z]+$/))`, []string{"Tags"}},
+			{`NOT ANY .A (ALL .B (. > 1) OR .C == 1) AND .D == 1`, `((NOT (ANY .A ((ALL .B (. > 1)) OR (.C == 1)))) AND (.D == 1))`, []string{"A", "D"}},
+			{`ANY .Items (len(.) > 2 AND . IN .Allowed)`, `(ANY .Items ((len(.) > 2) AND (. IN .Allowed)))`, []string{"Items"}},
+			{`. == 1`, `(. == 1)`, nil},
+		} {
+			stmnt, err := Compile(test.query)
+			So(err, ShouldBeNil)
+			So(stmnt.String(), ShouldEqual, test.output)
+			So(stmnt.ContextKeys, ShouldEqual, test.keys)
+			again, err := Compile(stmnt.String())
+			So(err, ShouldBeNil)
+			So(again.String(), ShouldEqual, test.output)
+			loaded, e := CompileJSON([]byte(stmnt.Stringify()))
+			So(e, ShouldBeNil)
+			So(loaded.Equal(stmnt), ShouldBeTrue)
+		}
+
+		for _, query := range []string{
+			`ANY .A .B == 1`,
+			`ANY .A ()`,
+			`ANY (.A == 1)`,
+			`ALL .A (.B == 1`,
+			`ANY .A (.B)`,
+			`. IS NULL 1`,
+		} {
+			_, err := Compile(query)
+			So(err, ShouldNotBeNil)
+		}
+
+		stmnt, err := Compile(`all ."Tags" (. == "x") and ANY .B (.Z == 1 OR .Y == 2)`)
+		So(err, ShouldBeNil)
+		So(stmnt.Format(), ShouldEqual, `((ALL .Tags (. == 'x')) AND (ANY .B ((.Y == 2) OR (.Z == 1))))`)
+
+		stmnt, e := NewStatement(And(
+			Any("Authors", Eq("Name", StringValue("Ann"))),
+			All("Tags", OpValue(SelfValue(), "=~", RegexpValue("^[a-z]+$"))),
+		))
+		So(e, ShouldBeNil)
+		So(stmnt.String(), ShouldEqual, `((ANY .Authors (.Name == 'Ann')) AND (ALL .Tags (. =~ m/^[a-z]+$/)))`)
+		_, e = NewStatement(Any("A B", Eq("Name", IntValue(1))))
+		So(e, ShouldNotBeNil)
+		_, e = NewStatement(&Expression{Quantifier: &Quantifier{Type: "SOME", ContextKey: "A", Expression: Eq("B", IntValue(1))}})
+		So(e, ShouldNotBeNil)
+		_, e = NewStatement(Any("A", nil))
+		So(e, ShouldNotBeNil)
+
+		// keys within the quantified expression refer to the items
+		stmnt, _ = Compile(`ANY .Name (.Name == 'x') AND .Name == 'y'`)
+		renamed, e := stmnt.Rewrite(RenameKey("Name", "Title"))
+		So(e, ShouldBeNil)
+		So(renamed.String(), ShouldEqual, `((ANY .Title (.Name == 'x')) AND (.Title == 'y'))`)
+		So(renamed.ContextKeys, ShouldEqual, []string{"Title"})
+
+		stmnt, _ = Compile(`ANY .Autors (.Nmae == 'x')`)
+		suggestions := stmnt.Suggest([]string{"Authors"}, nil)
+		So(suggestions, ShouldHaveLength, 1)
+		So(suggestions[0].String(), ShouldEqual, `unknown context key .Autors, did you mean .Authors?`)
+	})
+
+	Convey("Arithmetic", t, func() {
+		for _, test := range []struct {
+			query  string
+			output string
+			keys   []string
+		}{
+			{`(.Stock - .Reserved > 0)`, `(.Stock - .Reserved > 0)`, []string{"Reserved", "Stock"}},
+			{`.Price*1.2<=.Budget`, `(.Price * 1.2 <= .Budget)`, []string{"Budget", "Price"}},
+			{`.A - 1 == .B / .C % 2 * -3 - 1`, `(.A - 1 == .B / .C % 2 * -3 - 1)`, []string{"A", "B", "C"}},
+			{`.First + ' ' + .Last == 'Ann Smith'`, `(.First + ' ' + .Last == 'Ann Smith')`, []string{"First", "Last"}},
+			{`len(.Tags) % 2 == 0 AND ANY .Items (. * 2 > 10)`, `((len(.Tags) % 2 == 0) AND (ANY .Items (. * 2 > 10)))`, []string{"Items", "Tags"}},
+		} {
+			stmnt, err := Compile(test.query)
+			So(err, ShouldBeNil)
+			So(stmnt.String(), ShouldEqual, test.output)
+			So(stmnt.ContextKeys, ShouldEqual, test.keys)
+			again, err := Compile(stmnt.String())
+			So(err, ShouldBeNil)
+			So(again.Equal(stmnt), ShouldBeTrue)
+			loaded, e := CompileJSON([]byte(stmnt.Stringify()))
+			So(e, ShouldBeNil)
+			So(loaded.Equal(stmnt), ShouldBeTrue)
+		}
+
+		// * / and % are applied before + and -
+		stmnt, _ := Compile(`.A == .B + .C * .D - .E`)
+		sum := stmnt.Expression.Operation.Right.Arithmetic
+		So(sum.Operator, ShouldEqual, "-")
+		So(sum.Left.Arithmetic.Operator, ShouldEqual, "+")
+		So(sum.Left.Arithmetic.Right.Arithmetic.Operator, ShouldEqual, "*")
+
+		for _, query := range []string{
+			`.A == 'x' * 2`,
+			`.A == 'x' - 'y'`,
+			`.A == 'x' + 1`,
+			`.A == 1 + true`,
+			`.A == 1 + nil`,
+			`.A == .B + m/x/`,
+			`.A == 1d * 2`,
+			`.A == now() % 1h`,
+			`.A == .B *`,
+			`.A == .B ** 2`,
+			`.A / 2`,
+		} {
+			_, err := Compile(query)
+			So(err, ShouldNotBeNil)
+		}
+
+		stmnt, e := NewStatement(Le("Price", ArithmeticValue(ArithmeticValue(KeyValue("Cost"), "*", FloatValue(1.2)), "+", IntValue(5))))
+		So(e, ShouldBeNil)
+		So(stmnt.String(), ShouldEqual, `(.Price <= .Cost * 1.2 + 5)`)
+		stmnt, e = NewStatement(Le("Price", ArithmeticValue(KeyValue("Cost"), "-", ArithmeticValue(KeyValue("Discount"), "/", IntValue(2)))))
+		So(e, ShouldBeNil)
+		So(stmnt.String(), ShouldEqual, `(.Price <= .Cost - .Discount / 2)`)
+		// operands which would need grouping cannot be represented
+		_, e = NewStatement(Le("Price", ArithmeticValue(ArithmeticValue(KeyValue("Cost"), "-", IntValue(1)), "*", IntValue(2))))
+		So(e, ShouldNotBeNil)
+		_, e = NewStatement(Le("Price", ArithmeticValue(KeyValue("Cost"), "/", ArithmeticValue(KeyValue("A"), "*", IntValue(2)))))
+		So(e, ShouldNotBeNil)
+	})
+}